@@ -0,0 +1,47 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterRegistrationSpec defines the desired ArgoCD registration of a cluster.
+type ClusterRegistrationSpec struct {
+	// KubeConfigSecretRef references the key of a Secret, in the same namespace, holding the cluster kubeconfig.
+	KubeConfigSecretRef corev1.SecretKeySelector `json:"kubeConfigSecretRef"`
+
+	// Project is the ArgoCD AppProject the cluster is bound to.
+	// +optional
+	Project string `json:"project,omitempty"`
+
+	// Namespaces restricts ArgoCD to manage only these namespaces of the cluster.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Labels are added to the generated ArgoCD cluster secret.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterRegistration registers a cluster in ArgoCD from an arbitrary kubeconfig secret.
+type ClusterRegistration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterRegistrationSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterRegistrationList contains a list of ClusterRegistration.
+type ClusterRegistrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRegistration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterRegistration{}, &ClusterRegistrationList{})
+}
@@ -3,15 +3,30 @@
 package controllers
 
 import (
-	// b64 "encoding/base64"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	// "errors"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/rest"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -20,42 +35,270 @@ import (
 var (
 	// ArgoNamespace represents the Namespace that hold ArgoCluster secrets.
 	ArgoNamespace string
+	// AllowedArgoNamespaces restricts the namespaces ArgoSecrets are written to, whether
+	// ArgoNamespace or routed via clusterArgoInstanceKey. Empty allows ArgoNamespace only, so
+	// routing is opt-in.
+	AllowedArgoNamespaces []string
+	// AllowServerTemplates syncs Clusters annotated with clusterServerTemplateKey, which are
+	// skipped otherwise, as it lets the author of a Cluster point its credentials to any server.
+	AllowServerTemplates bool
 	// TestKubeConfig represents
 	TestKubeConfig *rest.Config
+	// EnableCompressConfig enables gzip compression of config blobs that exceed the Secret size limit.
+	// Upstream ArgoCD cannot read compressed configs, so this is experimental.
+	EnableCompressConfig bool
+
+	// ConfigSource controls which config fields are derived from the kubeconfig.
+	ConfigSource = ConfigSourceKubeConfig
+	// CredentialsSecret references the secret holding credentials when ConfigSource is ConfigSourceServerCAOnly.
+	CredentialsSecret types.NamespacedName
+	// ServerSource controls where the ArgoCD server is derived from.
+	ServerSource = ServerSourceKubeConfig
+	// CredentialPreference controls which credential is registered for kubeconfigs holding
+	// both a bearer token and a client certificate.
+	CredentialPreference = CredentialPreferenceBoth
+	// OmitBearerToken registers clusters without a bearer token, eg. for ArgoCD to
+	// authenticate through impersonation instead.
+	OmitBearerToken bool
+
+	// CABundleConfigMap references a ConfigMap (eg. distributed by trust-manager) holding
+	// a PEM CA bundle under CABundleKey, used when the kubeconfig has no CA.
+	CABundleConfigMap types.NamespacedName
+	// CABundleKey is the CABundleConfigMap key holding the CA bundle.
+	CABundleKey string
+	// ForceCABundle uses the CABundleConfigMap bundle even when the kubeconfig has a CA.
+	ForceCABundle bool
+
+	// ClusterSelector restricts the registered clusters to the ones whose Cluster object matches it.
+	ClusterSelector labels.Selector
+	// ClusterNameAllowlist restricts the registered clusters to the listed ones, as either
+	// <name> or <namespace>/<name>. Empty allows all clusters.
+	ClusterNameAllowlist []string
+
+	// OverlongLabelPolicy controls take-along label values exceeding the label value limit.
+	OverlongLabelPolicy = OverlongLabelPolicySkip
+	// MaxTakeAlongLabels caps the take-along labels of an ArgoSecret, keeping the first ones
+	// in key order. Zero disables the cap.
+	MaxTakeAlongLabels int
+	// WarnEmptyTakeAlongValues warns about take-along labels whose value on the Cluster is
+	// empty, which often points to a bug upstream. They are still taken along.
+	WarnEmptyTakeAlongValues bool
+
+	// ApplicationSetLabels are static labels set on every ArgoSecret, eg. for ApplicationSet
+	// cluster generators to select CACO-managed clusters.
+	ApplicationSetLabels map[string]string
+	// TemplatedLabels are labels set on every ArgoSecret, rendered from the fields of the
+	// Cluster, eg. team={{.Labels.team}}.
+	TemplatedLabels map[string]*template.Template
+
+	// SelfServer is the API server of the cluster CACO runs in, set from its rest config.
+	SelfServer string
+	// AllowSelfRegistration syncs CAPI secrets pointing to SelfServer, which are skipped otherwise.
+	AllowSelfRegistration bool
+
+	// RejectLoopbackServers skips clusters whose server is a loopback address, eg. left in the
+	// kubeconfig by a misconfigured provider, which ArgoCD could never reach.
+	RejectLoopbackServers = true
+
+	// WaitForReadyCondition holds off registering clusters until their Ready condition is True.
+	WaitForReadyCondition bool
+	// ReadyConditionRequeueAfter is the delay before checking again the Ready condition of a
+	// cluster that is not ready yet.
+	ReadyConditionRequeueAfter = 30 * time.Second
+
+	// ArgoNameKey, ArgoServerKey and ArgoConfigKey are the ArgoSecret data keys holding the
+	// cluster name, server and config, for ArgoCD forks departing from the upstream ones.
+	ArgoNameKey   = "name"
+	ArgoServerKey = "server"
+	ArgoConfigKey = "config"
+
+	// KubernetesVersionLabel labels ArgoSecrets with the Kubernetes version of the cluster
+	// topology, or its control plane, eg. for ApplicationSets to target clusters by version.
+	// Empty disables it.
+	KubernetesVersionLabel = "capi-to-argocd/k8s-version"
+
+	// SanitizeNames normalizes generated ArgoSecret names into valid DNS-1123 subdomains.
+	SanitizeNames bool
+
+	// ErrConfigTooLarge is returned when a generated config does not fit in a Secret.
+	ErrConfigTooLarge = errors.New("config exceeds secret size limit")
+
+	// ErrArgoSecretNameTooLong is returned when a generated ArgoSecret name exceeds the
+	// Kubernetes object name limit.
+	ErrArgoSecretNameTooLong = errors.New("ArgoSecret name too long")
 )
 
 const (
-	clusterTakeAlongKey        = "take-along-label.capi-to-argocd."
-	clusterTakenFromClusterKey = "taken-from-cluster-label.capi-to-argocd."
-	clusterIgnoreKey           = "ignore-cluster.capi-to-argocd"
+	clusterTakeAlongKey           = "take-along-label.capi-to-argocd."
+	clusterTakenFromClusterKey    = "taken-from-cluster-label.capi-to-argocd."
+	clusterTakeAlongAnnotationKey = "take-along-annotation.capi-to-argocd."
+	annotationTakenFromClusterKey = "taken-from-cluster-annotation.capi-to-argocd."
+	clusterIgnoreKey              = "ignore-cluster.capi-to-argocd"
+
+	// clusterTakeAlongValuesKey prefixes cluster annotations restricting a take-along label
+	// to the values matching any of the comma-separated glob patterns of the annotation.
+	clusterTakeAlongValuesKey = "take-along-label-values.capi-to-argocd."
+
+	// managedKeyPrefix prefixes the labels and annotations CACO manages on ArgoSecrets.
+	// Any other label or annotation, except take-along ones, is left untouched.
+	managedKeyPrefix = "capi-to-argocd/"
+
+	// ConfigSourceKubeConfig takes server, CA and credentials from the kubeconfig.
+	ConfigSourceKubeConfig = "kubeconfig"
+	// ConfigSourceServerCAOnly takes only server and CA from the kubeconfig, credentials come from CredentialsSecret.
+	ConfigSourceServerCAOnly = "server-ca-only"
+
+	// CredentialPreferenceBoth registers both the bearer token and the client certificate.
+	CredentialPreferenceBoth = "both"
+	// CredentialPreferenceToken registers only the bearer token when both are present.
+	CredentialPreferenceToken = "token"
+	// CredentialPreferenceCert registers only the client certificate when both are present.
+	CredentialPreferenceCert = "cert"
+
+	// ServerSourceKubeConfig takes the ArgoCD server from the kubeconfig.
+	ServerSourceKubeConfig = "kubeconfig"
+	// ServerSourceControlPlaneEndpoint takes the ArgoCD server from the Cluster spec.controlPlaneEndpoint.
+	ServerSourceControlPlaneEndpoint = "control-plane-endpoint"
+
+	// clusterHeaderPrefix marks cluster annotations holding custom headers for the ArgoCD API client,
+	// eg. capi-to-argocd/header.X-Tenant: a.
+	clusterHeaderPrefix = "capi-to-argocd/header."
+
+	// OverlongLabelPolicySkip drops over-long take-along label values.
+	OverlongLabelPolicySkip = "skip"
+	// OverlongLabelPolicyAnnotate takes over-long take-along label values along as annotations.
+	OverlongLabelPolicyAnnotate = "annotate"
+	// OverlongLabelPolicyTruncate truncates over-long take-along label values.
+	OverlongLabelPolicyTruncate = "truncate"
+
+	// clusterReadOnlyKey is read as an annotation from the cluster and set as a label on the ArgoSecret.
+	clusterReadOnlyKey = "capi-to-argocd/readonly"
+
+	// clusterInClusterKey is read as an annotation from the cluster, registering it as the
+	// cluster ArgoCD runs in.
+	clusterInClusterKey = "capi-to-argocd/in-cluster"
+
+	// clusterArgoInstanceKey is read as an annotation from the cluster, routing its ArgoSecret
+	// to the ArgoCD instance of the given namespace instead of ArgoNamespace.
+	clusterArgoInstanceKey = "capi-to-argocd/argocd-instance"
+
+	// clusterConfigOverridesKey is read as an annotation from the cluster, holding a JSON object
+	// deep-merged over the generated ArgoCD cluster config.
+	clusterConfigOverridesKey = "capi-to-argocd/config-overrides"
+
+	// clusterRegisterAfterKey is read as an annotation from the cluster, holding a duration
+	// to wait after the creation of its source before registering it in ArgoCD.
+	clusterRegisterAfterKey = "capi-to-argocd/register-after"
+
+	// clusterServerTemplateKey is read as an annotation from the cluster, holding a Go template
+	// of the server rendered against the Cluster (eg. https://{{.Name}}.api.example.com:6443),
+	// taking precedence over the kubeconfig server.
+	clusterServerTemplateKey = "capi-to-argocd/server-template"
+
+	// clusterServerSideDiffKey is read as an annotation from the cluster, passed through to
+	// the serverSideDiff field of the generated ArgoCD cluster config.
+	clusterServerSideDiffKey = "capi-to-argocd/server-side-diff"
+
+	// clusterTenantKey is read as an annotation from the cluster, registering it for a single
+	// tenant: bound to the AppProject named by the annotation, restricted to the namespaces of
+	// clusterTenantNamespacesKey (defaulting to the project name), without cluster-scoped resources.
+	clusterTenantKey           = "capi-to-argocd/tenant"
+	clusterTenantNamespacesKey = "capi-to-argocd/tenant-namespaces"
+
+	// clusterProjectKey is read as an annotation, or else a label, from the cluster, binding it
+	// to the given AppProject. A tenant, see clusterTenantKey, takes precedence.
+	clusterProjectKey = "capi-to-argocd/project"
+
+	// clusterNamespacesKey and clusterClusterResourcesKey are read as annotations from the
+	// cluster, restricting ArgoCD to the comma-separated namespaces, and allowing it to manage
+	// cluster-scoped resources all the same. A tenant, see clusterTenantKey, takes precedence.
+	clusterNamespacesKey       = "capi-to-argocd/namespaces"
+	clusterClusterResourcesKey = "capi-to-argocd/clusterResources"
+
+	// InClusterServer is the server of the cluster ArgoCD runs in, which ArgoCD reaches
+	// with its own ServiceAccount.
+	InClusterServer = "https://kubernetes.default.svc"
+
+	// clusterProviderKey labels the ArgoSecret with the infrastructure provider kind of the cluster (eg. AWSCluster).
+	clusterProviderKey = "capi-to-argocd/provider"
+
+	// protectedAnnotation, set to "true" on a source or its ArgoSecret, keeps the ArgoSecret
+	// from ever being garbage collected. Sources pass it on to their ArgoSecret.
+	protectedAnnotation = "capi-to-argocd/protected"
+
+	// pausedAnnotation, set to "true" on OperatorNamespace, pauses all reconciles.
+	pausedAnnotation = "capi-to-argocd/paused"
+
+	// reconciledByAnnotation records the ReconcilerIdentity that last wrote an ArgoSecret.
+	reconciledByAnnotation = "capi-to-argocd/reconciled-by"
+
+	// rotationAnnotation records on the ArgoSecret the value of the RotationAnnotation of
+	// its source, as of the last config rewrite.
+	rotationAnnotation = "capi-to-argocd/rotation"
+
+	// configHashAnnotation holds the sha256 of the config stored in the ArgoSecret.
+	configHashAnnotation = "capi-to-argocd/config-hash"
+
+	// applicationSetLabelsAnnotation records the ApplicationSetLabels set on an ArgoSecret,
+	// see recordLabels.
+	applicationSetLabelsAnnotation = "capi-to-argocd/applicationset-labels"
+
+	// templatedLabelsAnnotation records the TemplatedLabels set on an ArgoSecret.
+	templatedLabelsAnnotation = "capi-to-argocd/templated-labels"
+
+	// configEncodingAnnotation marks secrets whose config is stored compressed.
+	configEncodingAnnotation = "capi-to-argocd/config-encoding"
+	configEncodingGzip       = "gzip"
+	// argoConfigSizeLimit is the maximum size of a Secret's data.
+	argoConfigSizeLimit = 1024 * 1024
 )
 
 // GetArgoCommonLabels holds a map of labels that reconciled objects must have.
 func GetArgoCommonLabels() map[string]string {
-	return map[string]string{
-		"capi-to-argocd/owned":           "true",
-		"argocd.argoproj.io/secret-type": "cluster",
+	l := map[string]string{}
+	for key, value := range ApplicationSetLabels {
+		l[key] = value
 	}
+	l["capi-to-argocd/owned"] = "true"
+	l["argocd.argoproj.io/secret-type"] = "cluster"
+	return l
 }
 
 // ArgoCluster holds all information needed for CAPI --> Argo Cluster conversion
 type ArgoCluster struct {
-	NamespacedName  types.NamespacedName
-	ClusterName     string
-	ClusterServer   string
-	ClusterLabels   map[string]string
-	TakeAlongLabels map[string]string
-	ClusterConfig   ArgoConfig
+	NamespacedName       types.NamespacedName
+	ClusterName          string
+	ClusterServer        string
+	ClusterLabels        map[string]string
+	TakeAlongLabels      map[string]string
+	TakeAlongAnnotations map[string]string
+	Project              string
+	Namespaces           []string
+	// ClusterResources allows ArgoCD to manage cluster-scoped resources when Namespaces is set.
+	ClusterResources bool
+	ClusterConfig    ArgoConfig
+	// InCluster registers the cluster ArgoCD runs in, see clusterInClusterKey.
+	InCluster bool
+	// ConfigOverrides are deep-merged over ClusterConfig, see clusterConfigOverridesKey.
+	ConfigOverrides map[string]interface{}
+	// RegisterAfter delays the creation of the ArgoSecret, see clusterRegisterAfterKey.
+	RegisterAfter time.Duration
+	// TemplateData holds the Cluster fields TemplatedLabels are rendered against.
+	TemplateData map[string]interface{}
 }
 
 // ArgoConfig represents Argo Cluster.JSON.config
 type ArgoConfig struct {
 	TLSClientConfig *ArgoTLS `json:"tlsClientConfig,omitempty"`
-	BearerToken     *string  `json:"bearerToken,omitempty"`
+	// BearerToken is an opaque string ArgoCD sends as-is, so it is stored raw. Unlike the
+	// ArgoTLS data fields, it is never base64 encoded nor validated as such.
+	BearerToken *string           `json:"bearerToken,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
 }
 
 // ArgoTLS represents Argo Cluster.JSON.config.tlsClientConfig
 type ArgoTLS struct {
+	Insecure bool    `json:"insecure,omitempty"`
 	CaData   *string `json:"caData,omitempty"`
 	CertData *string `json:"certData,omitempty"`
 	KeyData  *string `json:"keyData,omitempty"`
@@ -66,48 +309,472 @@ func NewArgoCluster(c *CapiCluster, s *corev1.Secret, cluster *clusterv1.Cluster
 	log := ctrl.Log.WithName("argoCluster")
 
 	takeAlongLabels := map[string]string{}
+	takeAlongAnnotations := map[string]string{}
 	var errList []string
 	if cluster != nil {
-		takeAlongLabels, errList = buildTakeAlongLabels(cluster)
+		takeAlongLabels, takeAlongAnnotations, errList, _ = buildCachedTakeAlong(cluster)
 		for _, e := range errList {
 			log.Info(e)
 		}
 	}
-	return &ArgoCluster{
-		NamespacedName: BuildNamespacedName(s.ObjectMeta.Name, s.ObjectMeta.Namespace),
-		ClusterName:    BuildClusterName(c.KubeConfig.Clusters[0].Name, s.ObjectMeta.Namespace),
-		ClusterServer:  c.KubeConfig.Clusters[0].Cluster.Server,
-		ClusterLabels: map[string]string{
-			"capi-to-argocd/cluster-secret-name": c.Name + "-kubeconfig",
-			"capi-to-argocd/cluster-namespace":   c.Namespace,
-		},
-		TakeAlongLabels: takeAlongLabels,
+	clusterLabels := map[string]string{
+		"capi-to-argocd/cluster-secret-name": c.Name + "-kubeconfig",
+		"capi-to-argocd/cluster-namespace":   c.Namespace,
+	}
+	if cluster != nil && cluster.Annotations[clusterReadOnlyKey] == "true" {
+		clusterLabels[clusterReadOnlyKey] = "true"
+	}
+	if cluster != nil && cluster.Spec.InfrastructureRef != nil && cluster.Spec.InfrastructureRef.Kind != "" {
+		clusterLabels[clusterProviderKey] = cluster.Spec.InfrastructureRef.Kind
+	}
+	if version := kubernetesVersion(cluster); KubernetesVersionLabel != "" && version != "" {
+		clusterLabels[KubernetesVersionLabel] = version
+	}
+
+	namespacedName := BuildNamespacedName(s.ObjectMeta.Name, s.ObjectMeta.Namespace, cluster)
+	if err := ValidateArgoSecretName(namespacedName.Name); err != nil {
+		if errors.Is(err, ErrArgoSecretNameTooLong) {
+			argoSecretNameTooLongTotal.Inc()
+		}
+		return nil, err
+	}
+
+	// User-less kubeconfigs are accepted with AllowEmptyUsers and produce caData-only configs.
+	var user UserInfo
+	if len(c.KubeConfig.Users) > 0 {
+		user = c.KubeConfig.Users[0].User
+	}
+	observeCertExpiry(log, c.Namespace+"/"+c.Name, user.CertData)
+
+	// Without a Cluster, templates see the name and namespace of the CAPI secret cluster only.
+	templateData := map[string]interface{}{
+		"Name":        c.Name,
+		"Namespace":   c.Namespace,
+		"Labels":      map[string]string{},
+		"Annotations": map[string]string{},
+	}
+	if cluster != nil {
+		templateData = clusterTemplateData(cluster)
+	}
+
+	argoCluster := &ArgoCluster{
+		NamespacedName:       namespacedName,
+		ClusterName:          BuildClusterName(c.KubeConfig.Clusters[0].Name, s.ObjectMeta.Namespace),
+		ClusterServer:        c.KubeConfig.Clusters[0].Cluster.Server,
+		ClusterLabels:        clusterLabels,
+		TakeAlongLabels:      takeAlongLabels,
+		TakeAlongAnnotations: takeAlongAnnotations,
+		TemplateData:         templateData,
 		ClusterConfig: ArgoConfig{
-			BearerToken: c.KubeConfig.Users[0].User.Token,
+			BearerToken: user.Token,
 			TLSClientConfig: &ArgoTLS{
 				CaData:   &c.KubeConfig.Clusters[0].Cluster.CaData,
-				CertData: c.KubeConfig.Users[0].User.CertData,
-				KeyData:  c.KubeConfig.Users[0].User.KeyData,
+				CertData: user.CertData,
+				KeyData:  user.KeyData,
 			},
 		},
-	}, nil
+	}
+
+	// Servers skipping TLS verification have no CA to verify against, and ArgoCD rejects
+	// insecure configs that set one.
+	if c.KubeConfig.Clusters[0].Cluster.InsecureSkipTLSVerify {
+		argoCluster.ClusterConfig.TLSClientConfig.Insecure = true
+		argoCluster.ClusterConfig.TLSClientConfig.CaData = nil
+	}
+
+	if cluster != nil {
+		argoCluster.ClusterConfig.Headers = buildHeaders(cluster.Annotations)
+		argoCluster.Project = cluster.Annotations[clusterProjectKey]
+		if argoCluster.Project == "" {
+			argoCluster.Project = cluster.Labels[clusterProjectKey]
+		}
+		argoCluster.Namespaces = splitNamespaces(cluster.Annotations[clusterNamespacesKey])
+		if value := cluster.Annotations[clusterClusterResourcesKey]; value != "" {
+			clusterResources, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s annotation, expected a boolean: %w", clusterClusterResourcesKey, err)
+			}
+			argoCluster.ClusterResources = clusterResources
+		}
+		if tenant := cluster.Annotations[clusterTenantKey]; tenant != "" {
+			argoCluster.setTenant(tenant, cluster.Annotations[clusterTenantNamespacesKey])
+		}
+		if overrides, ok := cluster.Annotations[clusterConfigOverridesKey]; ok {
+			if err := json.Unmarshal([]byte(overrides), &argoCluster.ConfigOverrides); err != nil {
+				return nil, fmt.Errorf("invalid %s annotation, expected a JSON object: %w", clusterConfigOverridesKey, err)
+			}
+		}
+		if value, ok := cluster.Annotations[clusterRegisterAfterKey]; ok {
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("invalid %s annotation, expected a positive duration: %q", clusterRegisterAfterKey, value)
+			}
+			argoCluster.RegisterAfter = delay
+		}
+		if value, ok := cluster.Annotations[clusterServerSideDiffKey]; ok {
+			serverSideDiff, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s annotation, expected a boolean: %w", clusterServerSideDiffKey, err)
+			}
+			// Passed through as an override, leaving one set by config-overrides in place.
+			if argoCluster.ConfigOverrides == nil {
+				argoCluster.ConfigOverrides = map[string]interface{}{}
+			}
+			if _, ok := argoCluster.ConfigOverrides["serverSideDiff"]; !ok {
+				argoCluster.ConfigOverrides["serverSideDiff"] = serverSideDiff
+			}
+		}
+	}
+
+	// Clusters without a control plane endpoint yet fall back to the kubeconfig server.
+	if ServerSource == ServerSourceControlPlaneEndpoint {
+		if cluster != nil && cluster.Spec.ControlPlaneEndpoint.IsValid() {
+			argoCluster.ClusterServer = buildControlPlaneServer(cluster.Spec.ControlPlaneEndpoint)
+		} else {
+			log.Info("Cluster has no controlPlaneEndpoint, using the kubeconfig server", "cluster", c.Name)
+		}
+	}
+
+	// A server template takes precedence over both, eg. for clusters reached through custom DNS.
+	if cluster != nil && cluster.Annotations[clusterServerTemplateKey] != "" {
+		server, err := renderServerTemplate(cluster.Annotations[clusterServerTemplateKey], cluster)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", clusterServerTemplateKey, err)
+		}
+		argoCluster.ClusterServer = server
+	}
+
+	// Credentials are supplied out-of-band, see SetCredentials.
+	if ConfigSource == ConfigSourceServerCAOnly {
+		argoCluster.ClusterConfig.BearerToken = nil
+		argoCluster.ClusterConfig.TLSClientConfig.CertData = nil
+		argoCluster.ClusterConfig.TLSClientConfig.KeyData = nil
+	}
+
+	if OmitBearerToken {
+		argoCluster.ClusterConfig.BearerToken = nil
+	}
+	argoCluster.preferCredential()
+
+	// ArgoCD uses its own ServiceAccount for the in-cluster endpoint, so no credentials are set.
+	if cluster != nil && cluster.Annotations[clusterInClusterKey] == "true" {
+		argoCluster.InCluster = true
+		argoCluster.ClusterServer = InClusterServer
+		argoCluster.ClusterConfig.BearerToken = nil
+		argoCluster.ClusterConfig.TLSClientConfig = &ArgoTLS{}
+	}
+	return argoCluster, nil
+}
+
+// setTenant binds the ArgoCluster to the tenant project, restricted to the comma-separated
+// namespaces, or to the namespace named after the project when empty.
+func (a *ArgoCluster) setTenant(project string, namespaces string) {
+	a.Project = project
+	a.Namespaces = splitNamespaces(namespaces)
+	if len(a.Namespaces) == 0 {
+		a.Namespaces = []string{project}
+	}
+	a.ClusterResources = false
+}
+
+// splitNamespaces splits comma-separated namespaces, dropping empty ones.
+func splitNamespaces(namespaces string) []string {
+	var l []string
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			l = append(l, ns)
+		}
+	}
+	return l
+}
+
+// setContext names the ArgoCluster after one of the contexts of a kubeconfig registering
+// several, as <name>-<context>.
+func (a *ArgoCluster) setContext(context string) error {
+	suffix := "-" + sanitizeName(context)
+	if err := ValidateArgoSecretName(a.NamespacedName.Name + suffix); err != nil {
+		return err
+	}
+	a.NamespacedName.Name += suffix
+	a.ClusterName += suffix
+	return nil
+}
+
+// clusterTemplateData exposes the Name, Namespace, Labels and Annotations of the Cluster
+// to templates.
+func clusterTemplateData(cluster *clusterv1.Cluster) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":        cluster.Name,
+		"Namespace":   cluster.Namespace,
+		"Labels":      cluster.Labels,
+		"Annotations": cluster.Annotations,
+	}
+}
+
+// ParseTemplatedLabels parses comma-separated key=template pairs into TemplatedLabels
+// templates, eg. team={{.Labels.team}},tenant={{.Namespace}}.
+func ParseTemplatedLabels(s string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, tmpl, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid templated label %q, expected key=template", pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid templated label key %q: %s", key, strings.Join(errs, ", "))
+		}
+		t, err := template.New(key).Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid templated label %q: %w", key, err)
+		}
+		templates[key] = t
+	}
+	return templates, nil
+}
+
+// renderTemplatedLabel renders the templated label t against data, and checks that the
+// result is a legal label value.
+func renderTemplatedLabel(t *template.Template, data map[string]interface{}) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	value := b.String()
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return "", fmt.Errorf("rendered value %q is not a valid label value: %s", value, strings.Join(errs, ", "))
+	}
+	return value, nil
+}
+
+// renderServerTemplate renders the server template tmpl against the Cluster, exposing its
+// Name, Namespace, Labels and Annotations, and checks that the result is a server URL.
+func renderServerTemplate(tmpl string, cluster *clusterv1.Cluster) (string, error) {
+	t, err := template.New(clusterServerTemplateKey).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, clusterTemplateData(cluster)); err != nil {
+		return "", err
+	}
+	server := b.String()
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return "", fmt.Errorf("rendered server %q is not an http(s) URL", server)
+	}
+	return server, nil
+}
+
+// isSelfServer returns true when server points to the cluster CACO runs in, ie. SelfServer
+// or InClusterServer.
+func isSelfServer(server string) bool {
+	target := normalizeServer(server)
+	if target == "" {
+		return false
+	}
+	for _, self := range []string{SelfServer, InClusterServer} {
+		if normalizeServer(self) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackServer returns true when the host of server is localhost, a subdomain of it, or
+// a loopback IP address. Hostnames are not resolved.
+func isLoopbackServer(server string) bool {
+	u, err := url.Parse(server)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// normalizeServer returns the lowercased host:port of server, defaulting the port from the
+// scheme, or an empty string if server is not a valid URL.
+func normalizeServer(server string) string {
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// sameServer returns true when both servers point to the same endpoint, treating an
+// explicit default port and a trailing slash as equivalent to their absence. Any other
+// port, eg. 6443, has to match exactly.
+func sameServer(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil || !strings.EqualFold(ua.Scheme, ub.Scheme) {
+		return false
+	}
+	host := normalizeServer(a)
+	return host != "" && host == normalizeServer(b) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/")
+}
+
+// buildControlPlaneServer returns the ArgoCD server of a Cluster controlPlaneEndpoint.
+func buildControlPlaneServer(endpoint clusterv1.APIEndpoint) string {
+	return "https://" + net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port)))
+}
+
+// SetCredentials sets the ArgoCluster credentials from the bearerToken, certData
+// and keyData keys of a secret, as used with ConfigSourceServerCAOnly.
+func (a *ArgoCluster) SetCredentials(s *corev1.Secret) error {
+	token, hasToken := s.Data["bearerToken"]
+	cert, hasCert := s.Data["certData"]
+	key, hasKey := s.Data["keyData"]
+	if !hasToken && !(hasCert && hasKey) {
+		return errors.New("credentials secret has neither bearerToken nor certData/keyData keys")
+	}
+	if hasToken && !OmitBearerToken {
+		t := string(token)
+		a.ClusterConfig.BearerToken = &t
+	}
+	if hasCert && hasKey {
+		c, k := string(cert), string(key)
+		a.ClusterConfig.TLSClientConfig.CertData = &c
+		a.ClusterConfig.TLSClientConfig.KeyData = &k
+	}
+	a.preferCredential()
+	return nil
+}
+
+// preferCredential drops either the bearer token or the client certificate according to
+// CredentialPreference, when both are set, so that ArgoCD is not left to pick one.
+func (a *ArgoCluster) preferCredential() {
+	tls := a.ClusterConfig.TLSClientConfig
+	if a.ClusterConfig.BearerToken == nil || tls == nil || tls.CertData == nil || tls.KeyData == nil {
+		return
+	}
+	switch CredentialPreference {
+	case CredentialPreferenceToken:
+		tls.CertData, tls.KeyData = nil, nil
+	case CredentialPreferenceCert:
+		a.ClusterConfig.BearerToken = nil
+	}
+}
+
+// SetCAData sets the ArgoCluster CA from a PEM bundle, unless it already has one and force is false.
+func (a *ArgoCluster) SetCAData(bundle string, force bool) error {
+	if bundle == "" {
+		return errors.New("CA bundle is empty")
+	}
+	if a.ClusterConfig.TLSClientConfig == nil {
+		a.ClusterConfig.TLSClientConfig = &ArgoTLS{}
+	}
+	ca := a.ClusterConfig.TLSClientConfig.CaData
+	if a.ClusterConfig.TLSClientConfig.Insecure || (!force && ca != nil && *ca != "") {
+		return nil
+	}
+	caData := b64.StdEncoding.EncodeToString([]byte(bundle))
+	a.ClusterConfig.TLSClientConfig.CaData = &caData
+	return nil
 }
 
 // extractTakeAlongLabel returns the take-along label key from a cluster resource
 func extractTakeAlongLabel(key string) (string, error) {
-	if strings.HasPrefix(key, clusterTakeAlongKey) {
-		splitResult := strings.Split(key, clusterTakeAlongKey)
+	return extractTakeAlongKey(key, clusterTakeAlongKey, "label")
+}
+
+// extractTakeAlongAnnotation returns the take-along annotation key from a cluster resource
+func extractTakeAlongAnnotation(key string) (string, error) {
+	return extractTakeAlongKey(key, clusterTakeAlongAnnotationKey, "annotation")
+}
+
+// extractTakeAlongKey returns the key that follows the given take-along prefix
+func extractTakeAlongKey(key string, prefix string, kind string) (string, error) {
+	if strings.HasPrefix(key, prefix) {
+		splitResult := strings.Split(key, prefix)
 		if len(splitResult) >= 2 {
 			if splitResult[1] != "" {
 				return splitResult[1], nil
 			}
 		}
-		return "", fmt.Errorf("invalid take-along label. missing key after '/': %s", key)
+		return "", fmt.Errorf("invalid take-along %s. missing key after '/': %s", kind, key)
 	}
-	// Not an take-along label. Return nil
+	// Not an take-along key. Return nil
 	return "", nil
 }
 
+// validateClusterSelector returns true when the cluster matches ClusterSelector, or no selector is set.
+func validateClusterSelector(cluster *clusterv1.Cluster) bool {
+	return ClusterSelector == nil || ClusterSelector.Matches(labels.Set(cluster.Labels))
+}
+
+// validateClusterAllowlist returns true when the cluster is on ClusterNameAllowlist, or no
+// allowlist is set.
+func validateClusterAllowlist(namespace string, name string) bool {
+	return len(ClusterNameAllowlist) == 0 || slices.Contains(ClusterNameAllowlist, name) ||
+		slices.Contains(ClusterNameAllowlist, namespace+"/"+name)
+}
+
+// validateArgoNamespace returns true when namespace is on AllowedArgoNamespaces, or is
+// ArgoNamespace when no allowlist is set. Routing via clusterArgoInstanceKey is thus denied
+// unless explicitly allowed.
+func validateArgoNamespace(namespace string) bool {
+	if len(AllowedArgoNamespaces) == 0 {
+		return namespace == ArgoNamespace
+	}
+	return slices.Contains(AllowedArgoNamespaces, namespace)
+}
+
+// kubernetesVersion returns the Kubernetes version of the cluster topology as a label value,
+// see versionLabelValue. Clusters without a topology carry the version of their control
+// plane instead, see Capi2Argo.controlPlaneVersion.
+func kubernetesVersion(cluster *clusterv1.Cluster) string {
+	if cluster == nil || cluster.Spec.Topology == nil {
+		return ""
+	}
+	return versionLabelValue(cluster.Spec.Topology.Version)
+}
+
+// versionLabelValue returns version as a label value, with build metadata separators
+// (eg. v1.30.2+rke2r1) replaced. Invalid versions are ignored.
+func versionLabelValue(version string) string {
+	version = strings.ReplaceAll(version, "+", "_")
+	if len(validation.IsValidLabelValue(version)) > 0 {
+		return ""
+	}
+	return version
+}
+
+// validateClusterReady returns true when WaitForReadyCondition is disabled or the cluster
+// Ready condition is True.
+func validateClusterReady(cluster *clusterv1.Cluster) bool {
+	if !WaitForReadyCondition {
+		return true
+	}
+	for _, c := range cluster.GetConditions() {
+		if c.Type == clusterv1.ReadyCondition {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // validateClusterIgnoreLabel returns true when the cluster has the clusterIgnoreKey label
 func validateClusterIgnoreLabel(cluster *clusterv1.Cluster) bool {
 	clusterLabels := cluster.Labels
@@ -120,48 +787,252 @@ func validateClusterIgnoreLabel(cluster *clusterv1.Cluster) bool {
 	return false
 }
 
-// buildTakeAlongLabels returns a list of valid take-along labels from a cluster
-func buildTakeAlongLabels(cluster *clusterv1.Cluster) (map[string]string, []string) {
-	name := cluster.Name
-	namespace := cluster.Namespace
-	clusterLabels := cluster.Labels
+// takeAlongCounts counts the take-along labels of a cluster reported by metrics on every
+// build, even when reused from takeAlongCache.
+type takeAlongCounts struct {
+	empty   int
+	dropped int
+}
 
-	takeAlongLabels := []string{}
-	// Check labels keys that begin with clusterTakeAlongKey and extract the value after the last '/
-	for k := range clusterLabels {
-		l, err := extractTakeAlongLabel(k)
+// record adds c to the take-along metrics.
+func (c takeAlongCounts) record() {
+	takeAlongEmptyValuesTotal.Add(float64(c.empty))
+	takeAlongLabelsDroppedTotal.Add(float64(c.dropped))
+}
+
+// buildTakeAlongLabels returns a list of valid take-along labels from a cluster.
+// Values exceeding the label value limit are handled according to OverlongLabelPolicy.
+func buildTakeAlongLabels(cluster *clusterv1.Cluster) (map[string]string, []string) {
+	takeAlongLabels, errList, counts := buildCountedTakeAlongLabels(cluster)
+	counts.record()
+	return takeAlongLabels, errList
+}
+
+// buildCountedTakeAlongLabels is buildTakeAlongLabels, returning the counts to report
+// instead of recording them.
+func buildCountedTakeAlongLabels(cluster *clusterv1.Cluster) (map[string]string, []string, takeAlongCounts) {
+	var counts takeAlongCounts
+	takeAlongLabels, errList := buildTakeAlong(cluster.Name, cluster.Namespace, cluster.Labels, extractTakeAlongLabel, clusterTakenFromClusterKey, "label")
+	errList = append(errList, filterTakeAlongValues(cluster, takeAlongLabels)...)
+	if WarnEmptyTakeAlongValues {
+		empty := emptyTakeAlongLabels(takeAlongLabels)
+		counts.empty = len(empty)
+		for _, key := range empty {
+			errList = append(errList, fmt.Sprintf("take-along label '%s' has an empty value, taking it along anyway", key))
+		}
+	}
+	for key, value := range overlongLabels(takeAlongLabels) {
+		switch OverlongLabelPolicy {
+		case OverlongLabelPolicyTruncate:
+			takeAlongLabels[key] = truncateLabelValue(value)
+			errList = append(errList, fmt.Sprintf("take-along label '%s' exceeds %d characters, truncating", key, validation.LabelValueMaxLength))
+			continue
+		case OverlongLabelPolicyAnnotate:
+			errList = append(errList, fmt.Sprintf("take-along label '%s' exceeds %d characters, moving to annotations", key, validation.LabelValueMaxLength))
+		default:
+			errList = append(errList, fmt.Sprintf("take-along label '%s' exceeds %d characters, skipping", key, validation.LabelValueMaxLength))
+		}
+		delete(takeAlongLabels, key)
+		delete(takeAlongLabels, clusterTakenFromClusterKey+key)
+	}
+	if dropped := capTakeAlongLabels(takeAlongLabels, MaxTakeAlongLabels); len(dropped) > 0 {
+		counts.dropped = len(dropped)
+		errList = append(errList, fmt.Sprintf("take-along labels exceed the limit of %d, dropping: %s", MaxTakeAlongLabels, strings.Join(dropped, ", ")))
+	}
+	return takeAlongLabels, errList, counts
+}
+
+// emptyTakeAlongLabels returns the keys of the take-along labels with an empty value, in
+// key order, leaving out the taken-from markers, which are always empty.
+func emptyTakeAlongLabels(takeAlongLabels map[string]string) []string {
+	var keys []string
+	for key, value := range takeAlongLabels {
+		if value == "" && !strings.HasPrefix(key, clusterTakenFromClusterKey) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// capTakeAlongLabels drops the take-along labels past the first limit ones in key order,
+// in-place, and returns the dropped keys. A limit of zero keeps all of them.
+func capTakeAlongLabels(takeAlongLabels map[string]string, limit int) []string {
+	var keys []string
+	for key := range takeAlongLabels {
+		if !strings.HasPrefix(key, clusterTakenFromClusterKey) {
+			keys = append(keys, key)
+		}
+	}
+	if limit <= 0 || len(keys) <= limit {
+		return nil
+	}
+	slices.Sort(keys)
+	for _, key := range keys[limit:] {
+		delete(takeAlongLabels, key)
+		delete(takeAlongLabels, clusterTakenFromClusterKey+key)
+	}
+	return keys[limit:]
+}
+
+// buildTakeAlongAnnotations returns a list of valid take-along annotations from a cluster.
+// With OverlongLabelPolicyAnnotate, it includes take-along labels exceeding the label value limit.
+func buildTakeAlongAnnotations(cluster *clusterv1.Cluster) (map[string]string, []string) {
+	takeAlongAnnotations, errList := buildTakeAlong(cluster.Name, cluster.Namespace, cluster.Annotations, extractTakeAlongAnnotation, annotationTakenFromClusterKey, "annotation")
+	if OverlongLabelPolicy != OverlongLabelPolicyAnnotate || takeAlongAnnotations == nil {
+		return takeAlongAnnotations, errList
+	}
+	takeAlongLabels, _ := buildTakeAlong(cluster.Name, cluster.Namespace, cluster.Labels, extractTakeAlongLabel, clusterTakenFromClusterKey, "label")
+	filterTakeAlongValues(cluster, takeAlongLabels)
+	for key, value := range overlongLabels(takeAlongLabels) {
+		if _, ok := takeAlongAnnotations[key]; ok {
+			continue
+		}
+		takeAlongAnnotations[key] = value
+		takeAlongAnnotations[annotationTakenFromClusterKey+key] = ""
+	}
+	return takeAlongAnnotations, errList
+}
+
+// filterTakeAlongValues drops the take-along labels whose value does not match the patterns
+// of their clusterTakeAlongValuesKey annotation, if any.
+func filterTakeAlongValues(cluster *clusterv1.Cluster, takeAlongLabels map[string]string) []string {
+	var errList []string
+	for key, value := range takeAlongLabels {
+		patterns, ok := cluster.Annotations[clusterTakeAlongValuesKey+key]
+		if !ok || matchesAnyPattern(value, strings.Split(patterns, ",")) {
+			continue
+		}
+		errList = append(errList, fmt.Sprintf("take-along label '%s' value '%s' does not match '%s', skipping", key, value, patterns))
+		delete(takeAlongLabels, key)
+		delete(takeAlongLabels, clusterTakenFromClusterKey+key)
+	}
+	return errList
+}
+
+// matchesAnyPattern returns true when value matches any of the glob patterns.
+func matchesAnyPattern(value string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(strings.TrimSpace(p), value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// buildHeaders returns the custom headers set through clusterHeaderPrefix annotations, or nil if none.
+func buildHeaders(annotations map[string]string) map[string]string {
+	var headers map[string]string
+	for key, value := range annotations {
+		name, ok := strings.CutPrefix(key, clusterHeaderPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+// overlongLabels returns the entries of l whose value exceeds the label value limit.
+func overlongLabels(l map[string]string) map[string]string {
+	overlong := map[string]string{}
+	for key, value := range l {
+		if len(value) > validation.LabelValueMaxLength {
+			overlong[key] = value
+		}
+	}
+	return overlong
+}
+
+// truncateLabelValue cuts value to the label value limit, keeping it a valid label value.
+func truncateLabelValue(value string) string {
+	return strings.TrimRight(value[:validation.LabelValueMaxLength], "-_.")
+}
+
+// buildTakeAlong copies the keys marked as take-along from source, alongside
+// the takenKey-prefixed bookkeeping entries used to detect later removals.
+func buildTakeAlong(name string, namespace string, source map[string]string, extract func(string) (string, error), takenKey string, kind string) (map[string]string, []string) {
+	takeAlongKeys := []string{}
+	// Check keys that begin with the take-along prefix and extract the value after the last '/
+	for k := range source {
+		l, err := extract(k)
 		if err != nil {
 			return nil, []string{err.Error()}
 		}
 		if l != "" {
-			takeAlongLabels = append(takeAlongLabels, l)
+			takeAlongKeys = append(takeAlongKeys, l)
 		}
 	}
 
-	takeAlongLabelsMap := make(map[string]string)
+	takeAlongMap := make(map[string]string)
 
 	errors := []string{}
-	if len(takeAlongLabels) > 0 {
-		for _, label := range takeAlongLabels {
-			if label != "" {
-				if _, ok := clusterLabels[label]; !ok {
-					errors = append(errors, fmt.Sprintf("take-along label '%s' not found on cluster resource: %s, namespace: %s. Ignoring", label, name, namespace))
+	if len(takeAlongKeys) > 0 {
+		for _, key := range takeAlongKeys {
+			if key != "" {
+				if _, ok := source[key]; !ok {
+					errors = append(errors, fmt.Sprintf("take-along %s '%s' not found on cluster resource: %s, namespace: %s. Ignoring", kind, key, name, namespace))
 					continue
 				}
-				takeAlongLabelsMap[label] = clusterLabels[label]
-				takeAlongLabelsMap[fmt.Sprintf("%s%s", clusterTakenFromClusterKey, label)] = ""
+				takeAlongMap[key] = source[key]
+				takeAlongMap[fmt.Sprintf("%s%s", takenKey, key)] = ""
 			}
 		}
 	}
-	return takeAlongLabelsMap, errors
+	return takeAlongMap, errors
 }
 
-// BuildNamespacedName returns k8s native object identifier.
-func BuildNamespacedName(s string, namespace string) types.NamespacedName {
-	return types.NamespacedName{
-		Name:      "cluster-" + BuildClusterName(strings.TrimSuffix(s, "-kubeconfig"), namespace),
-		Namespace: ArgoNamespace,
+// renderLabels returns labels as comma-separated key=value pairs sorted by key,
+// so that log lines and events listing them are reproducible.
+func renderLabels(l map[string]string) string {
+	return labels.FormatLabels(l)
+}
+
+// BuildNamespacedName returns k8s native object identifier. The ArgoSecret targets the
+// ArgoCD instance the cluster is routed to via clusterArgoInstanceKey, if any.
+func BuildNamespacedName(s string, namespace string, cluster *clusterv1.Cluster) types.NamespacedName {
+	name := "cluster-" + BuildClusterName(strings.TrimSuffix(s, "-kubeconfig"), namespace)
+	if SanitizeNames {
+		name = sanitizeName(name)
 	}
+	target := ArgoNamespace
+	if cluster != nil && cluster.Annotations[clusterArgoInstanceKey] != "" {
+		target = cluster.Annotations[clusterArgoInstanceKey]
+	}
+	return types.NamespacedName{
+		Name:      name,
+		Namespace: target,
+	}
+}
+
+// sanitizeName turns s into a valid DNS-1123 subdomain, by lowercasing it, replacing
+// invalid characters with '-' and trimming it to the maximum length.
+func sanitizeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(s))
+	if len(s) > validation.DNS1123SubdomainMaxLength {
+		s = s[:validation.DNS1123SubdomainMaxLength]
+	}
+	return strings.Trim(s, "-.")
+}
+
+// ValidateArgoSecretName checks that name is usable as a Secret name.
+func ValidateArgoSecretName(name string) error {
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		return fmt.Errorf("%w: %q is %d characters, over the limit of %d (enable --sanitize-names to truncate it)",
+			ErrArgoSecretNameTooLong, name, len(name), validation.DNS1123SubdomainMaxLength)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid ArgoSecret name %q (enable --sanitize-names to normalize it): %s", name, strings.Join(errs, ", "))
+	}
+	return nil
 }
 
 // BuildClusterName returns cluster name after transformations applied (with/without namespace suffix, etc).
@@ -173,12 +1044,45 @@ func BuildClusterName(s string, namespace string) string {
 	return prefix + s
 }
 
+// marshalConfig returns the JSON ArgoCD cluster config, with ConfigOverrides merged in.
+func (a *ArgoCluster) marshalConfig() ([]byte, error) {
+	c, err := json.Marshal(a.ClusterConfig)
+	if err != nil || len(a.ConfigOverrides) == 0 {
+		return c, err
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(c, &merged); err != nil {
+		return nil, err
+	}
+	deepMerge(merged, a.ConfigOverrides)
+	return json.Marshal(merged)
+}
+
+// deepMerge merges src into dst, recursing into objects present in both. Null values in
+// src remove the field from dst.
+func deepMerge(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		srcObject, srcOK := value.(map[string]interface{})
+		dstObject, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			deepMerge(dstObject, srcObject)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
 // ConvertToSecret converts an ArgoCluster into k8s native secret object.
 func (a *ArgoCluster) ConvertToSecret() (*corev1.Secret, error) {
-	// if err := ValidateClusterTLSConfig(&a.ClusterConfig.TLSClientConfig); err != nil {
-	// 	return nil, err
-	// }
-	c, err := json.Marshal(a.ClusterConfig)
+	c, err := a.marshalConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, encoding, err := encodeArgoConfig(c)
 	if err != nil {
 		return nil, err
 	}
@@ -190,40 +1094,102 @@ func (a *ArgoCluster) ConvertToSecret() (*corev1.Secret, error) {
 	for key, value := range a.ClusterLabels {
 		mergedLabels[key] = value
 	}
+	for key, t := range TemplatedLabels {
+		value, err := renderTemplatedLabel(t, a.TemplateData)
+		if err != nil {
+			return nil, fmt.Errorf("templated label %s: %w", key, err)
+		}
+		mergedLabels[key] = value
+	}
 	for key, value := range a.TakeAlongLabels {
 		mergedLabels[key] = value
 	}
 
+	annotations := map[string]string{
+		configHashAnnotation: configHash(c),
+	}
+	for key, value := range a.TakeAlongAnnotations {
+		annotations[key] = value
+	}
+	if encoding != "" {
+		annotations[configEncodingAnnotation] = encoding
+	}
+
 	argoSecret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      a.NamespacedName.Name,
-			Namespace: a.NamespacedName.Namespace,
-			Labels:    mergedLabels,
+			Name:        a.NamespacedName.Name,
+			Namespace:   a.NamespacedName.Namespace,
+			Labels:      mergedLabels,
+			Annotations: annotations,
 		},
 		Data: map[string][]byte{
-			"name":   []byte(a.ClusterName),
-			"server": []byte(a.ClusterServer),
-			"config": c,
+			ArgoNameKey:   []byte(a.ClusterName),
+			ArgoServerKey: []byte(a.ClusterServer),
+			ArgoConfigKey: c,
 		},
 	}
+	if a.Project != "" {
+		argoSecret.Data["project"] = []byte(a.Project)
+	}
+	if len(a.Namespaces) > 0 {
+		argoSecret.Data["namespaces"] = []byte(strings.Join(a.Namespaces, ","))
+	}
+	if len(a.Namespaces) > 0 || a.ClusterResources {
+		argoSecret.Data["clusterResources"] = []byte(strconv.FormatBool(a.ClusterResources))
+	}
+	recordLabels(argoSecret, applicationSetLabelsAnnotation, slices.Collect(maps.Keys(ApplicationSetLabels)))
+	recordLabels(argoSecret, templatedLabelsAnnotation, slices.Collect(maps.Keys(TemplatedLabels)))
 	return argoSecret, nil
 }
 
-// ValidateClusterTLSConfig validates that we got proper based64 k/v fields.
-// func ValidateClusterTLSConfig(a *ArgoTLS) error {
-// 	for _, v := range []string{a.CaData, a.CertData, a.KeyData} {
-// 		// Check if field.value is empty
-// 		if v == "" {
-// 			return errors.New("missing key on ArgoTLS config")
-// 		}
-// 		// Check if field.value is valid b64 encoded string
-// 		if _, err := b64.StdEncoding.DecodeString(v); err != nil {
-// 			return err
-// 		}
-// 	}
-// 	return nil
-// }
+// configHash returns the hex encoded sha256 of a config, as stored in the ArgoSecret.
+func configHash(c []byte) string {
+	sum := sha256.Sum256(c)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeArgoConfig returns the config as it should be stored in the ArgoSecret, along with
+// its encoding. Configs that fit in a Secret are returned as-is with an empty encoding.
+func encodeArgoConfig(c []byte) ([]byte, string, error) {
+	if len(c) <= argoConfigSizeLimit {
+		return c, "", nil
+	}
+	if !EnableCompressConfig {
+		return nil, "", fmt.Errorf("%w: %d bytes, enable --experimental-compress-config to store it compressed", ErrConfigTooLarge, len(c))
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(c); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	if buf.Len() > argoConfigSizeLimit {
+		return nil, "", fmt.Errorf("%w: %d bytes even after compression", ErrConfigTooLarge, buf.Len())
+	}
+	ctrl.Log.WithName("argoCluster").Info("Storing compressed config, upstream ArgoCD is not able to read it", "size", len(c), "compressed", buf.Len())
+	return buf.Bytes(), configEncodingGzip, nil
+}
+
+// decodeArgoConfig reverses encodeArgoConfig for a given encoding.
+func decodeArgoConfig(c []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return c, nil
+	case configEncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(c))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown config encoding: %s", encoding)
+	}
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// WriteConcurrency bounds the writes a single reconcile pass issues concurrently.
+var WriteConcurrency = 4
+
+// runBatch runs writes with at most WriteConcurrency of them in flight. Every write runs
+// regardless of the others failing, and their errors are aggregated.
+func runBatch(ctx context.Context, writes ...func(context.Context) error) error {
+	limit := max(WriteConcurrency, 1)
+	sem := make(chan struct{}, limit)
+	errs := make([]error, len(writes))
+	var wg sync.WaitGroup
+	for i, write := range writes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = write(ctx)
+		}()
+	}
+	wg.Wait()
+	// A single failure is returned as-is, so that callers can still inspect it.
+	return utilerrors.Reduce(utilerrors.NewAggregate(errs))
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRunBatchPartialFailure(t *testing.T) {
+	c := NewMockClient()
+	c.OnCreate = func(obj client.Object) error {
+		if obj.GetName() == "fail" {
+			return errors.New("create failed")
+		}
+		return nil
+	}
+	create := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			return c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ArgoNamespace}})
+		}
+	}
+
+	err := runBatch(context.Background(), create("a"), create("fail"), create("b"))
+	assert.EqualError(t, err, "create failed")
+	// Successful writes persist despite the failure.
+	for _, name := range []string{"a", "b"} {
+		assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ArgoNamespace}, &corev1.Secret{}))
+	}
+
+	// Multiple failures are aggregated.
+	fail := func(msg string) func(context.Context) error {
+		return func(context.Context) error { return errors.New(msg) }
+	}
+	err = runBatch(context.Background(), fail("x"), create("c"), fail("y"))
+	assert.ErrorContains(t, err, "x")
+	assert.ErrorContains(t, err, "y")
+
+	assert.Nil(t, runBatch(context.Background()))
+}
+
+func TestRunBatchConcurrency(t *testing.T) {
+	oldConf := WriteConcurrency
+	defer func() { WriteConcurrency = oldConf }()
+	WriteConcurrency = 2
+
+	var inFlight, peak atomic.Int32
+	write := func(context.Context) error {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-1)
+		return nil
+	}
+	assert.Nil(t, runBatch(context.Background(), write, write, write, write, write))
+	assert.Equal(t, int32(2), peak.Load())
+}
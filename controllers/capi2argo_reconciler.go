@@ -3,21 +3,43 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	goErr "errors"
+	"fmt"
 	"os"
+	"path"
+	"reflect"
 	"strconv"
 
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var (
@@ -27,6 +49,79 @@ var (
 	// EnableNamespacedNames represents a mode where the cluster name is always
 	// prepended by the cluster namespace in all generated secrets
 	EnableNamespacedNames bool
+
+	// SingleNamespace restricts the controller to a single namespace, holding both the
+	// CAPI secrets and the ArgoSecrets. Empty means cluster-wide.
+	SingleNamespace string
+
+	// WatchNamespaces restricts the sources synced to the namespaces matching any of these
+	// literal names or path.Match globs (eg. team-*). Empty watches all namespaces.
+	WatchNamespaces []string
+
+	// AllowRecreate enables deleting and recreating ArgoSecrets that cannot be updated in-place.
+	AllowRecreate bool
+
+	// IgnoreConfigFields are config fields, as dotted paths (eg. tlsClientConfig.serverName),
+	// whose out-of-band changes are not reverted, eg. the ones ArgoCD sets itself.
+	IgnoreConfigFields []string
+
+	// TakeoverSecretType takes over the ArgoCD cluster secrets named like an ArgoSecret but
+	// created by another tool, see canTakeOver.
+	TakeoverSecretType bool
+
+	// ReconcileTimeout bounds the time spent in a single reconcile, which is requeued when
+	// exceeded. Zero disables the timeout.
+	ReconcileTimeout time.Duration
+
+	// ReconcilerIdentity (eg. the pod name) is recorded on the ArgoSecrets written by this
+	// replica, under reconciledByAnnotation. Empty disables it.
+	ReconcilerIdentity string
+
+	// RotationAnnotation is the source annotation (eg. a rotation timestamp) whose changes
+	// force a rewrite of the config, even when it compares equal. Empty disables it.
+	RotationAnnotation string
+
+	// RequiredSourceLabels are label keys CAPI secrets must carry to be synced.
+	RequiredSourceLabels []string
+
+	// OperatorNamespace is the namespace CACO runs in. Annotating it with pausedAnnotation
+	// pauses all writes, eg. during incident response. Empty disables pausing.
+	OperatorNamespace string
+	// PausedRequeueAfter is the delay before retrying reconciles while paused.
+	PausedRequeueAfter = time.Minute
+	// PausedCheckTimeout bounds the read of OperatorNamespace checking for pausedAnnotation.
+	PausedCheckTimeout = 5 * time.Second
+
+	// GCRecheckDelay is the delay before reconciling a deleted source again, right before
+	// garbage collecting its ArgoSecrets, so that a source recreated meanwhile is synced instead.
+	GCRecheckDelay time.Duration
+
+	// FieldManager is the field manager CACO writes as, so that controllers sharing the
+	// ArgoSecrets can tell their managed fields apart.
+	FieldManager = "capi2argo"
+
+	// DriftCorrectionMode controls how out-of-sync ArgoSecrets are written back.
+	DriftCorrectionMode = DriftCorrectionModeUpdate
+
+	// clusterFetchBackoff bounds the retries of fetching the Cluster of a CapiSecret.
+	clusterFetchBackoff = wait.Backoff{Steps: 3, Duration: 50 * time.Millisecond, Factor: 2}
+
+	// ErrCrossNamespace is returned in single-namespace mode for sources or targets outside of SingleNamespace.
+	ErrCrossNamespace = goErr.New("cross-namespace operation not allowed in single-namespace mode")
+
+	// ErrShadowNamespace is returned when ShadowNamespace is the ArgoCD namespace, or used in single-namespace mode.
+	ErrShadowNamespace = goErr.New("shadow namespace must differ from the ArgoCD namespace and cannot be used in single-namespace mode")
+
+	// ErrArgoSecretNameCollision is returned when an ArgoSecret is already owned by another CAPI secret.
+	ErrArgoSecretNameCollision = goErr.New("ArgoSecret name already used by another CAPI secret")
+)
+
+const (
+	// DriftCorrectionModeUpdate writes back out-of-sync ArgoSecrets as a whole.
+	DriftCorrectionModeUpdate = "update"
+	// DriftCorrectionModePatch writes back only the changed fields of out-of-sync ArgoSecrets,
+	// as a merge patch, which does not conflict with concurrent writers of other fields.
+	DriftCorrectionModePatch = "patch"
 )
 
 func init() {
@@ -41,21 +136,100 @@ func init() {
 	EnableNamespacedNames, _ = strconv.ParseBool(os.Getenv("ENABLE_NAMESPACED_NAMES"))
 }
 
+// WithFieldManager returns a client writing as FieldManager.
+func WithFieldManager(c client.Client) client.Client {
+	return client.WithFieldOwner(c, FieldManager)
+}
+
 // Capi2Argo reconciles a Secret object
 type Capi2Argo struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Resync optionally enqueues secrets on demand (eg. on configuration changes).
+	Resync <-chan event.GenericEvent
+	// Status optionally aggregates reconcile outcomes into a ConfigMap.
+	Status *StatusReporter
+	// DryRun logs the ArgoSecrets that would be created, updated or deleted instead of
+	// writing them. The reconciler writes nothing else either, eg. shadow copies or sync
+	// statuses.
+	DryRun bool
+
+	// echoes holds the resourceVersion of the last update of each CAPI secret by CACO,
+	// see consumeEcho. It is set up along with the watches.
+	echoes *sync.Map
+	// owners holds the UID of each source owning its ArgoSecret through an ownerReference,
+	// see gcByOwnerReference. It is set up along with the watches.
+	owners *sync.Map
+	// gcPending holds since when each deleted source awaits garbage collection, see
+	// gcRecheckAfter. It is set up along with the watches.
+	gcPending *sync.Map
 }
 
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 // Reconcile holds all the logic for syncing CAPI to Argo Clusters.
-func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	// Hold configuration steady for the whole reconcile.
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	ctx, span := startSpan(ctx, "Reconcile", attribute.String("namespace", req.Namespace), attribute.String("name", req.Name))
+	summary := &runSummary{}
+	ctx = withRunSummary(ctx, summary)
+	defer func() {
+		span.SetAttributes(attribute.String("outcome", summary.outcome(err)))
+		endSpan(span, err)
+	}()
+
+	log := r.Log.WithValues("secret", req.NamespacedName)
+	reconcileCtx := ctx
+	if ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		reconcileCtx, cancel = context.WithTimeout(ctx, ReconcileTimeout)
+		defer cancel()
+	}
+
+	if paused(reconcileCtx, r.Client, log) {
+		log.Info("Reconciles are paused, requeueing...", "namespace", OperatorNamespace, "after", PausedRequeueAfter)
+		return ctrl.Result{RequeueAfter: PausedRequeueAfter}, nil
+	}
+	log.V(2).Info("Reconciling CapiSecret")
+	start := time.Now()
+
+	var capiSecret corev1.Secret
+	result, err = r.reconcile(reconcileCtx, req, &capiSecret)
+	log.V(2).Info("Reconciled CapiSecret", "duration", time.Since(start).String(), "error", err != nil)
+	observeReconcileDuration(ctx, time.Since(start))
+
+	// Free up the worker, the remaining work is picked up on requeue.
+	if goErr.Is(reconcileCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		log.Info("Reconcile timed out, requeueing", "timeout", ReconcileTimeout.String())
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Record the outcome on the CapiSecret, unless it is gone or not a CAPI secret at all.
+	if capiSecret.ResourceVersion != "" && ValidateCapiSecret(&capiSecret) == nil {
+		r.updateSyncStatus(ctx, &capiSecret, err)
+		r.Status.Record(req.NamespacedName, err)
+	} else {
+		r.Status.Forget(req.NamespacedName)
+	}
+	return result, err
+}
+
+// reconcile syncs the CapiSecret of given request, which it fetches into capiSecret.
+func (r *Capi2Argo) reconcile(ctx context.Context, req ctrl.Request, capiSecret *corev1.Secret) (ctrl.Result, error) {
 	log := r.Log.WithValues("secret", req.NamespacedName)
 
-	// TODO: Check if secret is on allowed Namespaces.
+	if !ValidateWatchNamespace(req.Namespace) {
+		log.V(1).Info("Ignoring secret outside of the watched namespaces")
+		return ctrl.Result{}, nil
+	}
 
 	// Validate Secret.Metadata.Name complies with CAPI pattern: <clusterName>-kubeconfig
 	if !ValidateCapiNaming(req.NamespacedName) {
@@ -63,61 +237,317 @@ func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resul
 	}
 
 	// Fetch CapiSecret
-	var capiSecret corev1.Secret
-	err := r.Get(ctx, req.NamespacedName, &capiSecret)
+	err := r.Get(ctx, req.NamespacedName, capiSecret)
 	if err != nil {
 		// If we get error reading the object - requeue the request.
 		if client.IgnoreNotFound(err) != nil {
 			return ctrl.Result{}, err
 		}
 
-		// If secret is deleted and GC is enabled, mark ArgoSecret for deletion.
-		if EnableGarbageCollection {
-			labelSelector := map[string]string{
-				"capi-to-argocd/cluster-secret-name": req.NamespacedName.Name,
-				"capi-to-argocd/cluster-namespace":   req.NamespacedName.Namespace,
-			}
-			listOption := client.MatchingLabels(labelSelector)
-			secretList := &corev1.SecretList{}
-			err = r.List(context.Background(), secretList, listOption)
-			if err != nil {
-				log.Error(err, "Failed to list Cluster Secrets")
-				return ctrl.Result{}, err
-			}
-			if err := r.Delete(ctx, &secretList.Items[0]); err != nil {
-				log.Error(err, "Failed to delete ArgoSecret")
-				return ctrl.Result{}, err
-			}
-			log.Info("Deleted successfully of ArgoSecret")
+		// Deleted Clusters enqueue their <clusterName>-kubeconfig secret too, see clusterToCapiSecret.
+		forgetTakeAlong(clusterOfSource(req.NamespacedName))
+		if !EnableGarbageCollection {
 			return ctrl.Result{}, nil
 		}
-
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		// If secret is deleted and GC is enabled, mark ArgoSecret for deletion.
+		if wait := r.gcRecheckAfter(req.NamespacedName); wait > 0 {
+			log.Info("CapiSecret deleted, rechecking before garbage collection", "after", wait.String())
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		return ctrl.Result{}, r.garbageCollect(ctx, log, req.NamespacedName, capiSecret)
+	}
+	if r.forgetGCPending(req.NamespacedName) {
+		log.Info("CapiSecret reappeared, skipping garbage collection")
 	}
 	log.Info("Fetched CapiSecret")
 
 	// Validate CapiSecret.type is matching CAPI convention.
 	// if capiSecret.Type != "cluster.x-k8s.io/secret" {
-	err = ValidateCapiSecret(&capiSecret)
+	err = ValidateCapiSecret(capiSecret)
 	if err != nil {
 		log.Info("Ignoring secret as it's missing proper CAPI type", "type", capiSecret.Type)
 		return ctrl.Result{}, err
 	}
 
+	// Nothing changed since the last successful reconcile, but CACO's own status update.
+	if r.consumeEcho(req.NamespacedName, capiSecret.ResourceVersion) {
+		log.V(1).Info("Skipping echo of own CapiSecret update", "resourceVersion", capiSecret.ResourceVersion)
+		return ctrl.Result{}, nil
+	}
+
+	return r.sync(ctx, log, capiSecret, capiSecret)
+}
+
+// gcRecheckAfter returns how long to wait before garbage collecting the ArgoSecrets of the
+// deleted source nn, requeueing it meanwhile instead of holding a worker. The first reconcile
+// finding the source deleted marks it pending; once GCRecheckDelay elapsed, the mark is
+// cleared and zero returned, so a source gone for good is collected on that pass.
+func (r *Capi2Argo) gcRecheckAfter(nn types.NamespacedName) time.Duration {
+	if GCRecheckDelay <= 0 || r.gcPending == nil {
+		return 0
+	}
+	since, _ := r.gcPending.LoadOrStore(nn, time.Now())
+	if wait := GCRecheckDelay - time.Since(since.(time.Time)); wait > 0 {
+		return wait
+	}
+	r.gcPending.Delete(nn)
+	return 0
+}
+
+// forgetGCPending clears the pending garbage collection of the source nn, reporting whether
+// it was pending, ie. the source was recreated meanwhile.
+func (r *Capi2Argo) forgetGCPending(nn types.NamespacedName) bool {
+	if r.gcPending == nil {
+		return false
+	}
+	_, pending := r.gcPending.LoadAndDelete(nn)
+	return pending
+}
+
+// garbageCollect deletes the ArgoSecret generated from the source nn. When the source
+// itself was deleted, deleted is its (empty) object and ArgoSecrets owned by it through an
+// ownerReference are left to Kubernetes; it is nil otherwise.
+func (r *Capi2Argo) garbageCollect(ctx context.Context, log logr.Logger, nn types.NamespacedName, deleted client.Object) error {
+	secretList := &corev1.SecretList{}
+	err := r.List(ctx, secretList, sourceSelector(nn))
+	if err != nil {
+		log.Error(err, "Failed to list Cluster Secrets")
+		return err
+	}
+	var owner *metav1.OwnerReference
+	if deleted != nil {
+		if owner, err = r.deletedOwner(nn, deleted); err != nil {
+			log.Error(err, "Failed to resolve the kind of the deleted source")
+			return err
+		}
+	}
+	kubeConfigCertExpirySeconds.DeleteLabelValues(nn.Namespace + "/" + strings.TrimSuffix(nn.Name, "-kubeconfig"))
+	// Every ArgoSecret generated from the source is collected, eg. one per context with
+	// RegisterAllContexts, along with their shadow copies.
+	for i := range secretList.Items {
+		s := &secretList.Items[i]
+		shadow := isShadowSecret(s)
+		if isProtected(s) {
+			r.Recorder.Event(s, corev1.EventTypeWarning, "DeletionProtected",
+				fmt.Sprintf("Not garbage collecting protected ArgoSecret of %s, remove the %s annotation to allow it", nn, protectedAnnotation))
+			log.Info("ArgoSecret is protected, skipping deletion...", "shadow", shadow)
+			countRunOp(ctx, runSkipped)
+			continue
+		}
+		if owner != nil && gcByOwnerReference(*owner, nn.Namespace, s) {
+			log.V(1).Info("ArgoSecret is garbage collected through its ownerReference", "shadow", shadow)
+			continue
+		}
+		if r.DryRun {
+			log.Info("Dry-run, would delete ArgoSecret", "argoSecret", client.ObjectKeyFromObject(s), "shadow", shadow)
+			continue
+		}
+		if err := r.Delete(ctx, s); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete ArgoSecret", "shadow", shadow)
+			return err
+		}
+		if shadow {
+			log.Info("Deleted successfully of shadow ArgoSecret")
+			continue
+		}
+		secretsDeletedTotal.Inc()
+		countRunOp(ctx, runDeleted)
+		log.Info("Deleted successfully of ArgoSecret")
+	}
+	return nil
+}
+
+// unregister garbage collects the ArgoSecrets generated from source nn, if any, recording
+// an event with reason and message on source beforehand.
+func (r *Capi2Argo) unregister(ctx context.Context, log logr.Logger, source client.Object, nn types.NamespacedName, reason, message string) error {
+	secretList := &corev1.SecretList{}
+	if err := r.List(ctx, secretList, sourceSelector(nn)); err != nil {
+		log.Error(err, "Failed to list Cluster Secrets")
+		return err
+	}
+	if len(secretList.Items) == 0 {
+		return nil
+	}
+	r.Recorder.Event(source, corev1.EventTypeNormal, reason, message)
+	return r.garbageCollect(ctx, log, nn, nil)
+}
+
+// pruneStaleTargets deletes the ArgoSecrets generated from source nn other than targets,
+// eg. left behind after the cluster was routed to another ArgoCD instance.
+func (r *Capi2Argo) pruneStaleTargets(ctx context.Context, log logr.Logger, nn types.NamespacedName, targets ...types.NamespacedName) error {
+	secretList := &corev1.SecretList{}
+	if err := r.List(ctx, secretList, sourceSelector(nn)); err != nil {
+		log.Error(err, "Failed to list Cluster Secrets")
+		return err
+	}
+	for i := range secretList.Items {
+		s := &secretList.Items[i]
+		if isShadowSecret(s) || slices.Contains(targets, client.ObjectKeyFromObject(s)) || ValidateObjectOwner(*s) != nil {
+			continue
+		}
+		if isProtected(s) {
+			r.Recorder.Event(s, corev1.EventTypeWarning, "DeletionProtected",
+				fmt.Sprintf("Not pruning protected stale ArgoSecret of %s, remove the %s annotation to allow it", nn, protectedAnnotation))
+			log.Info("Stale ArgoSecret is protected, skipping deletion...", "stale", client.ObjectKeyFromObject(s))
+			countRunOp(ctx, runSkipped)
+			continue
+		}
+		if r.DryRun {
+			log.Info("Dry-run, would delete stale ArgoSecret", "stale", client.ObjectKeyFromObject(s))
+			continue
+		}
+		if err := r.Delete(ctx, s); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete stale ArgoSecret", "stale", client.ObjectKeyFromObject(s))
+			return err
+		}
+		secretsDeletedTotal.Inc()
+		countRunOp(ctx, runDeleted)
+		log.Info("Deleted successfully of stale ArgoSecret", "stale", client.ObjectKeyFromObject(s))
+	}
+	return nil
+}
+
+// sourceSelector matches the ArgoSecrets generated from source nn.
+func sourceSelector(nn types.NamespacedName) client.MatchingLabels {
+	return client.MatchingLabels{
+		"capi-to-argocd/cluster-secret-name": nn.Name,
+		"capi-to-argocd/cluster-namespace":   nn.Namespace,
+	}
+}
+
+// missingRequiredLabels returns the RequiredSourceLabels absent from l.
+func missingRequiredLabels(l map[string]string) []string {
+	var missing []string
+	for _, key := range RequiredSourceLabels {
+		if _, ok := l[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// isProtected returns true when o carries the protectedAnnotation.
+func isProtected(o client.Object) bool {
+	return o.GetAnnotations()[protectedAnnotation] == "true"
+}
+
+// dropOwnerReference removes the ownerReferences of s to uid, so that Kubernetes does not
+// garbage collect it along with its owner. It returns whether any was removed.
+func dropOwnerReference(s *corev1.Secret, uid types.UID) bool {
+	refs := slices.DeleteFunc(slices.Clone(s.OwnerReferences), func(o metav1.OwnerReference) bool {
+		return o.UID == uid
+	})
+	if len(refs) == len(s.OwnerReferences) {
+		return false
+	}
+	s.OwnerReferences = refs
+	return true
+}
+
+// rememberOwner records the UID of source once it owns its ArgoSecret through an
+// ownerReference, so that the reference can still be told apart once source is deleted.
+func (r *Capi2Argo) rememberOwner(source client.Object) {
+	if r.owners != nil {
+		r.owners.Store(client.ObjectKeyFromObject(source), source.GetUID())
+	}
+}
+
+// deletedOwner returns the ownerReference the deleted source nn set on its ArgoSecrets.
+// Its UID is empty when unknown, eg. after a restart, and then matches no ArgoSecret.
+func (r *Capi2Argo) deletedOwner(nn types.NamespacedName, deleted client.Object) (*metav1.OwnerReference, error) {
+	gvk, err := apiutil.GVKForObject(deleted, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	owner := &metav1.OwnerReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: nn.Name}
+	if r.owners == nil {
+		return owner, nil
+	}
+	if uid, ok := r.owners.LoadAndDelete(nn); ok {
+		owner.UID = uid.(types.UID)
+	}
+	return owner, nil
+}
+
+// gcByOwnerReference reports whether argoSecret is garbage collected by Kubernetes through
+// owner, the ownerReference of its source in namespace, matched on kind, apiVersion, name
+// and UID. Owner references can not cross namespaces, so this only applies to ArgoSecrets
+// living next to their source; others rely on label-based GC.
+func gcByOwnerReference(owner metav1.OwnerReference, namespace string, argoSecret *corev1.Secret) bool {
+	if argoSecret.Namespace != namespace || owner.UID == "" {
+		return false
+	}
+	for _, o := range argoSecret.OwnerReferences {
+		if o.APIVersion == owner.APIVersion && o.Kind == owner.Kind && o.Name == owner.Name && o.UID == owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// sync converts capiSecret into an ArgoSecret and creates or updates it. Events are
+// recorded on source, which is the object capiSecret was read from.
+func (r *Capi2Argo) sync(ctx context.Context, log logr.Logger, source client.Object, capiSecret *corev1.Secret) (ctrl.Result, error) {
+	if missing := missingRequiredLabels(capiSecret.Labels); len(missing) > 0 {
+		r.Recorder.Event(source, corev1.EventTypeWarning, "MissingRequiredLabels",
+			fmt.Sprintf("Missing required labels: %s", strings.Join(missing, ", ")))
+		log.Info("CapiSecret is missing required labels, skipping...", "missing", missing)
+		return ctrl.Result{}, nil
+	}
+
+	// Out-of-band credentials are only ever handed to CAPI secrets. Kubeconfig ConfigMaps are
+	// non-sensitive, and must not be turned into credentialed clusters by dropping their own.
+	if _, ok := source.(*corev1.Secret); !ok && ConfigSource == ConfigSourceServerCAOnly {
+		r.Recorder.Event(source, corev1.EventTypeWarning, "OutOfBandCredentials",
+			fmt.Sprintf("Kubeconfig ConfigMaps can not be synced with --config-source=%s", ConfigSourceServerCAOnly))
+		log.Info("Kubeconfig ConfigMaps can not use out-of-band credentials, skipping...", "config-source", ConfigSource)
+		return ctrl.Result{}, nil
+	}
+
 	// Construct CapiCluster from CapiSecret.
-	nn := strings.TrimSuffix(req.NamespacedName.Name, "-kubeconfig")
-	ns := req.NamespacedName.Namespace
+	nn := strings.TrimSuffix(capiSecret.Name, "-kubeconfig")
+	ns := capiSecret.Namespace
 	capiCluster := NewCapiCluster(nn, ns)
-	err = capiCluster.Unmarshal(&capiSecret)
+	err := capiCluster.Unmarshal(capiSecret)
 	if err != nil {
 		log.Error(err, "Failed to unmarshal CapiCluster")
 		return ctrl.Result{}, err
 	}
 
+	clusterName := capiSecretClusterName(capiSecret)
 	clusterObject := &clusterv1.Cluster{}
-	err = r.Get(ctx, types.NamespacedName{Name: capiSecret.Labels[clusterv1.ClusterNameLabel], Namespace: req.Namespace}, clusterObject)
-	if err != nil {
-		log.Info("Failed to get Cluster object", "error", err)
+	// Fetching the Cluster may fail for a moment, eg. while the cache is not started yet, so
+	// retry briefly instead of failing the reconcile. A missing Cluster is final though.
+	err = retry.OnError(clusterFetchBackoff, func(err error) bool { return !errors.IsNotFound(err) && ctx.Err() == nil }, func() error {
+		return r.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: ns}, clusterObject)
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Cluster object", "cluster", clusterName)
+		return ctrl.Result{}, err
+	}
+	clusterFound := err == nil
+	if !clusterFound {
+		log.Info("Cluster object not found", "cluster", clusterName)
+		forgetTakeAlong(types.NamespacedName{Name: clusterName, Namespace: ns})
+		// The selector matches the labels of the Cluster, so whether it is to be registered,
+		// or unregistered, is unknown until the Cluster is fetched. The Cluster watch brings
+		// the CapiSecret back once a missing Cluster is created.
+		if ClusterSelector != nil {
+			log.Info("The cluster can not be matched against the cluster label selector, skipping...", "cluster", clusterName)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Two Clusters may reference the same CapiSecret (eg. imported clusters sharing a kubeconfig),
+	// one through its cluster-name label and one through its <clusterName>-kubeconfig name. The
+	// labeled Cluster is always the one used.
+	if clusterFound && clusterName != nn {
+		if r.Get(ctx, types.NamespacedName{Name: nn, Namespace: ns}, &clusterv1.Cluster{}) == nil {
+			r.Recorder.Event(source, corev1.EventTypeWarning, "AmbiguousCluster",
+				fmt.Sprintf("CapiSecret is referenced by Clusters %s, through its %s label, and %s, through its name; using %s",
+					clusterName, clusterv1.ClusterNameLabel, nn, clusterName))
+			log.Info("CapiSecret is referenced by two Clusters, using the labeled one", "cluster", clusterName, "ignored", nn)
+		}
 	}
 
 	// Check if the cluster has the ignore label
@@ -126,27 +556,220 @@ func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resul
 		return ctrl.Result{}, nil
 	}
 
-	// Construct ArgoCluster from CapiCluster and CapiSecret.Metadata.
-	argoCluster, err := NewArgoCluster(capiCluster, &capiSecret, clusterObject)
-	if err != nil {
-		log.Error(err, "Failed to construct ArgoCluster")
-		return ctrl.Result{}, err
+	// Clusters not matching the selector are not registered, and unregistered if GC is enabled.
+	if !validateClusterSelector(clusterObject) {
+		log.Info("The cluster does not match the cluster label selector, skipping...", "selector", ClusterSelector.String())
+		if EnableGarbageCollection {
+			return ctrl.Result{}, r.garbageCollect(ctx, log, client.ObjectKeyFromObject(capiSecret), nil)
+		}
+		return ctrl.Result{}, nil
 	}
 
-	// Convert ArgoCluster into ArgoSecret to work natively on k8s objects.
-	log = r.Log.WithValues("cluster", argoCluster.NamespacedName)
-	argoSecret, err := argoCluster.ConvertToSecret()
-	if err != nil {
-		log.Error(err, "Failed to convert ArgoCluster to ArgoSecret")
-		return ctrl.Result{}, err
+	// Same for clusters not on the allowlist. Clusters without a Cluster resource, eg. imported
+	// kubeconfigs, are unregistered all the same.
+	if !validateClusterAllowlist(ns, clusterName) {
+		log.Info("The cluster is not on the cluster name allowlist, skipping...", "cluster", clusterName)
+		if !EnableGarbageCollection {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.unregister(ctx, log, source, client.ObjectKeyFromObject(capiSecret), "NotAllowlisted",
+			fmt.Sprintf("Unregistering cluster %s, it is not on the cluster name allowlist", clusterName))
 	}
 
+	// Clusters without a Cluster resource have no Ready condition to wait for.
+	if clusterFound && !validateClusterReady(clusterObject) {
+		log.Info("The cluster is not Ready yet, requeueing...", "after", ReadyConditionRequeueAfter)
+		return ctrl.Result{RequeueAfter: ReadyConditionRequeueAfter}, nil
+	}
+
+	// Server templates are opt-in, as they let the author of a Cluster redirect its credentials.
+	if !AllowServerTemplates && clusterObject.Annotations[clusterServerTemplateKey] != "" {
+		r.Recorder.Event(source, corev1.EventTypeWarning, "DisallowedServerTemplate",
+			fmt.Sprintf("The %s annotation is not allowed, enable --allow-server-templates to use it", clusterServerTemplateKey))
+		log.Info("The cluster has a server template, which is not allowed, skipping...")
+		return ctrl.Result{}, nil
+	}
+
+	// Kubeconfigs holding several contexts register each of them with RegisterAllContexts.
+	capiClusters := []*CapiCluster{capiCluster}
+	var contexts []string
+	if RegisterAllContexts && len(capiCluster.KubeConfig.Contexts) > 1 {
+		capiClusters, contexts, err = capiCluster.SplitContexts()
+		if err != nil {
+			log.Error(err, "Failed to split CapiCluster contexts")
+			return ctrl.Result{}, err
+		}
+	}
+
+	var version string
+	if KubernetesVersionLabel != "" && kubernetesVersion(clusterObject) == "" {
+		if version, err = r.controlPlaneVersion(ctx, clusterObject); err != nil {
+			log.Error(err, "Failed to get the Kubernetes version of the control plane")
+			return ctrl.Result{}, err
+		}
+	}
+
+	var writes []func(context.Context) error
+	results := make([]ctrl.Result, len(capiClusters))
+	targets := make([]types.NamespacedName, 0, len(capiClusters))
+	for i, c := range capiClusters {
+		// Construct ArgoCluster from CapiCluster and CapiSecret.Metadata.
+		argoCluster, err := NewArgoCluster(c, capiSecret, clusterObject)
+		if err != nil {
+			log.Error(err, "Failed to construct ArgoCluster")
+			return ctrl.Result{}, err
+		}
+		if version != "" {
+			argoCluster.ClusterLabels[KubernetesVersionLabel] = version
+		}
+		if contexts != nil {
+			if err := argoCluster.setContext(contexts[i]); err != nil {
+				log.Error(err, "Failed to construct ArgoCluster", "context", contexts[i])
+				return ctrl.Result{}, err
+			}
+		}
+
+		// Never register the cluster CACO runs in by accident (eg. through its own mounted kubeconfig),
+		// nor through the in-cluster annotation alone, which any Cluster author can set.
+		if !AllowSelfRegistration && isSelfServer(argoCluster.ClusterServer) {
+			r.Recorder.Event(source, corev1.EventTypeWarning, "SelfRegistration",
+				fmt.Sprintf("Server %s is the cluster CACO runs in, enable --allow-self-registration to register it", argoCluster.ClusterServer))
+			log.Info("CapiSecret points to the cluster CACO runs in, skipping...", "server", argoCluster.ClusterServer)
+			continue
+		}
+
+		// A misrouted cluster (eg. a typo in its argocd-instance annotation) is never registered.
+		if !validateArgoNamespace(argoCluster.NamespacedName.Namespace) {
+			allowed := AllowedArgoNamespaces
+			if len(allowed) == 0 {
+				allowed = []string{ArgoNamespace}
+			}
+			r.Recorder.Event(source, corev1.EventTypeWarning, "DisallowedArgoNamespace",
+				fmt.Sprintf("ArgoCD namespace %s is not allowed, allowed namespaces are: %s",
+					argoCluster.NamespacedName.Namespace, strings.Join(allowed, ", ")))
+			log.Info("ArgoCD namespace is not allowed, skipping...", "namespace", argoCluster.NamespacedName.Namespace)
+			continue
+		}
+
+		if RejectLoopbackServers && !argoCluster.InCluster && isLoopbackServer(argoCluster.ClusterServer) {
+			loopbackServersTotal.Inc()
+			r.Recorder.Event(source, corev1.EventTypeWarning, "LoopbackServer",
+				fmt.Sprintf("Server %s is a loopback address ArgoCD cannot reach, fix the kubeconfig or disable --reject-loopback-servers", argoCluster.ClusterServer))
+			log.Info("CapiSecret points to a loopback server, skipping...", "server", argoCluster.ClusterServer)
+			continue
+		}
+
+		// Clusters sharing a server (eg. behind the same gateway) confuse ArgoCD.
+		duplicate, registered, err := r.findDuplicateServer(ctx, client.ObjectKeyFromObject(capiSecret), argoCluster)
+		if err != nil {
+			log.Error(err, "Failed to list ArgoSecrets to check for duplicate servers")
+			return ctrl.Result{}, err
+		}
+		if duplicate != nil {
+			duplicateServersTotal.Inc()
+			r.Recorder.Event(source, corev1.EventTypeWarning, "DuplicateServer",
+				fmt.Sprintf("Server %s is already registered by ArgoSecret %s", argoCluster.ClusterServer, client.ObjectKeyFromObject(duplicate)))
+			if SkipDuplicateServers && !registered {
+				log.Info("Server is already registered by another ArgoSecret, skipping...", "server", argoCluster.ClusterServer, "duplicate", client.ObjectKeyFromObject(duplicate))
+				continue
+			}
+			log.Info("Server is already registered by another ArgoSecret", "server", argoCluster.ClusterServer, "duplicate", client.ObjectKeyFromObject(duplicate))
+		}
+
+		if err := setOutOfBandCredentials(ctx, r.Client, argoCluster); err != nil {
+			log.Error(err, "Failed to set ArgoCluster credentials", "credentials", CredentialsSecret)
+			return ctrl.Result{}, err
+		}
+
+		if err := setCABundle(ctx, r.Client, argoCluster); err != nil {
+			log.Error(err, "Failed to set ArgoCluster CA bundle", "configmap", CABundleConfigMap, "key", CABundleKey)
+			return ctrl.Result{}, err
+		}
+
+		if err := ValidateSingleNamespace(ns, argoCluster.NamespacedName.Namespace); err != nil {
+			log.Error(err, "Refusing to sync CapiSecret", "namespace", SingleNamespace)
+			return ctrl.Result{}, err
+		}
+
+		// Convert ArgoCluster into ArgoSecret to work natively on k8s objects.
+		log := r.Log.WithValues("cluster", argoCluster.NamespacedName)
+		argoSecret, err := argoCluster.ConvertToSecret()
+		if err != nil {
+			log.Error(err, "Failed to convert ArgoCluster to ArgoSecret")
+			return ctrl.Result{}, err
+		}
+		if isProtected(source) {
+			argoSecret.Annotations[protectedAnnotation] = "true"
+		}
+		if rotation := source.GetAnnotations()[RotationAnnotation]; RotationAnnotation != "" && rotation != "" {
+			argoSecret.Annotations[rotationAnnotation] = rotation
+		}
+
+		// The shadow copy is taken upfront, as apply may alter argoSecret concurrently.
+		shadowSource := argoSecret.DeepCopy()
+		writes = append(writes, func(ctx context.Context) (err error) {
+			results[i], err = r.apply(ctx, log, source, argoCluster, argoSecret)
+			return err
+		})
+		if ShadowNamespace != "" {
+			writes = append(writes, func(ctx context.Context) error {
+				return r.syncShadow(ctx, log, shadowSource)
+			})
+		}
+		targets = append(targets, argoCluster.NamespacedName)
+	}
+	if len(targets) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	err = runBatch(ctx, writes...)
+	var result ctrl.Result
+	for _, res := range results {
+		if !res.IsZero() {
+			result = res
+			break
+		}
+	}
+	if err != nil || !EnableGarbageCollection {
+		return result, err
+	}
+	return result, r.pruneStaleTargets(ctx, log, client.ObjectKeyFromObject(capiSecret), targets...)
+}
+
+// controlPlaneVersion returns the Kubernetes version of the control plane of the cluster,
+// eg. a KubeadmControlPlane, as a label value. The running version (status.version) is
+// preferred over the desired one (spec.version), so the label follows upgrades once done.
+// The version is left out while the control plane does not exist.
+func (r *Capi2Argo) controlPlaneVersion(ctx context.Context, cluster *clusterv1.Cluster) (string, error) {
+	ref := cluster.Spec.ControlPlaneRef
+	if ref == nil {
+		return "", nil
+	}
+	controlPlane := &unstructured.Unstructured{}
+	controlPlane.SetGroupVersionKind(ref.GroupVersionKind())
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, controlPlane); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	for _, field := range [][]string{{"status", "version"}, {"spec", "version"}} {
+		if version, _, _ := unstructured.NestedString(controlPlane.Object, field...); version != "" {
+			return versionLabelValue(version), nil
+		}
+	}
+	return "", nil
+}
+
+// apply creates argoSecret, or brings an existing ArgoSecret in-sync with it.
+func (r *Capi2Argo) apply(ctx context.Context, log logr.Logger, source client.Object, argoCluster *ArgoCluster, argoSecret *corev1.Secret) (ctrl.Result, error) {
 	// Represent a possible existing ArgoSecret.
 	var existingSecret corev1.Secret
 	var exists bool
 
 	// Check if ArgoSecret exists.
-	err = r.Get(ctx, argoCluster.NamespacedName, &existingSecret)
+	err := r.Get(ctx, argoCluster.NamespacedName, &existingSecret)
 	if errors.IsNotFound(err) {
 		exists = false
 		log.Info("ArgoSecret does not exists, creating..")
@@ -158,6 +781,16 @@ func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resul
 		return ctrl.Result{}, err
 	}
 
+	setReconciledBy(argoSecret)
+	// Kubernetes garbage collects owned ArgoSecrets, so they are only owned along with GC.
+	if EnableGarbageCollection && source.GetNamespace() == argoSecret.Namespace && !isProtected(argoSecret) {
+		if err := controllerutil.SetOwnerReference(source, argoSecret, r.Scheme); err != nil {
+			log.Error(err, "Failed to set ownerReference of ArgoSecret")
+			return ctrl.Result{}, err
+		}
+		r.rememberOwner(source)
+	}
+
 	// Reconcile ArgoSecret:
 	// - If does not exists:
 	//     1) Create it.
@@ -166,87 +799,120 @@ func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resul
 	//     2) If it is controller-managed, check if updates needed and apply them.
 	switch exists {
 	case false:
-		if err := r.Create(ctx, argoSecret); err != nil {
+		// Only the first registration is delayed, updates of registered clusters never are.
+		if wait := time.Until(source.GetCreationTimestamp().Add(argoCluster.RegisterAfter)); argoCluster.RegisterAfter > 0 && wait > 0 {
+			log.Info("Delaying registration of ArgoSecret", "after", wait.Round(time.Second))
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		if r.DryRun {
+			log.Info("Dry-run, would create ArgoSecret", "argoSecret", argoCluster.NamespacedName, "labels", renderLabels(argoSecret.Labels))
+			return ctrl.Result{}, nil
+		}
+		err := r.Create(ctx, argoSecret)
+		if err == nil {
+			secretsCreatedTotal.Inc()
+			countRunOp(ctx, runCreated)
+			log.Info("Created new ArgoSecret", "labels", renderLabels(argoSecret.Labels))
+			return ctrl.Result{}, nil
+		}
+		if !errors.IsAlreadyExists(err) {
 			log.Error(err, "Failed to create ArgoSecret")
 			return ctrl.Result{}, err
 		}
-		log.Info("Created new ArgoSecret")
-		return ctrl.Result{}, nil
+
+		// Someone else created it in the meantime, reconcile drift against their copy instead.
+		log.Info("ArgoSecret was created concurrently, checking state..")
+		if err := r.Get(ctx, argoCluster.NamespacedName, &existingSecret); err != nil {
+			log.Error(err, "Failed to fetch concurrently created ArgoSecret")
+			return ctrl.Result{}, err
+		}
+		fallthrough
 
 	case true:
 
-		log.Info("Checking if ArgoSecret is managed by the Controller")
+		log.V(1).Info("Checking if ArgoSecret is managed by the Controller")
+		takeover := false
 		err := ValidateObjectOwner(existingSecret)
 		if err != nil {
-			log.Info("Not managed by Controller, skipping...")
+			if !canTakeOver(existingSecret) {
+				log.Info("Not managed by Controller, skipping...")
+				return ctrl.Result{}, nil
+			}
+			log.Info("ArgoSecret was created by another tool, taking it over..")
+			takeover = true
+		}
+
+		// First writer wins: never take over an ArgoSecret generated from another CAPI secret.
+		if err := ValidateArgoSecretSource(existingSecret, argoSecret); err != nil && !takeover {
+			argoSecretNameCollisionsTotal.Inc()
+			r.Recorder.Event(source, corev1.EventTypeWarning, "NameCollision", err.Error())
+			log.Error(err, "Skipping CapiSecret, rename the cluster or enable namespaced names")
 			return ctrl.Result{}, nil
 		}
 
-		log.Info("Checking if ArgoSecret is out-of-sync with")
-		changed := false
-		if !bytes.Equal(existingSecret.Data["name"], []byte(argoCluster.ClusterName)) {
-			existingSecret.Data["name"] = []byte(argoCluster.ClusterName)
-			changed = true
-		}
-
-		if !bytes.Equal(existingSecret.Data["server"], []byte(argoCluster.ClusterServer)) {
-			existingSecret.Data["server"] = []byte(argoCluster.ClusterServer)
-			changed = true
-		}
-
-		if !bytes.Equal(existingSecret.Data["config"], []byte(argoSecret.Data["config"])) {
-			existingSecret.Data["config"] = []byte(argoSecret.Data["config"])
-			changed = true
-		}
-
-		// Check if take-along labels from argoCluster.TakeAlongLabels exist existingSecret.Labels and have the same values.
-		// If not set changed to true and update existingSecret.Labels.
-		log.Info("Checking for take-along labels")
-		log.Info("Take along labels", "labels", argoCluster.TakeAlongLabels)
-		argoSecretTakenAlongLabels := []string{}
-		for l := range argoCluster.TakeAlongLabels {
-			if strings.HasPrefix(l, clusterTakenFromClusterKey) {
-				key := strings.Split(l, clusterTakenFromClusterKey)[1]
-				argoSecretTakenAlongLabels = append(argoSecretTakenAlongLabels, key)
-			}
-		}
-		// Find difference between secrets prefixed with `taken-from-cluster-label.capi-to-argocd`
-		// between existingSecret.Labels and argoSecretTakenAlongLabels
-		// in order to handle removed 'take-from'-labels from the cluster resource
-		for k := range existingSecret.Labels {
-			if strings.HasPrefix(k, clusterTakenFromClusterKey) {
-				key := strings.Split(k, clusterTakenFromClusterKey)[1]
-				if !slices.Contains(argoSecretTakenAlongLabels, key) {
-					delete(existingSecret.Labels, k)
-					delete(existingSecret.Labels, key)
-					changed = true
+		log.V(1).Info("Checking if ArgoSecret is out-of-sync with")
+		var changed, rotated bool
+		var original *corev1.Secret
+		attempt := 0
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// A conflict means the ArgoSecret changed under us, so start over from its latest state.
+			if attempt > 0 {
+				if err := r.Get(ctx, argoCluster.NamespacedName, &existingSecret); err != nil {
+					return err
 				}
 			}
-		}
-
-		// Update secrets labels with current values
-		for k, v := range argoCluster.TakeAlongLabels {
-			// check if label exists in map
-			if val, ok := existingSecret.Labels[k]; ok {
-				// check if label value is the same
-				if val != v {
-					log.Info("Updating value of label in ArgoSecret", "label", k, "value", val)
-					existingSecret.Labels[k] = v
-					changed = true
-				}
-			} else {
-				log.Info("Adding missing label in ArgoSecret", "label", k)
-				existingSecret.Labels[k] = v
+			attempt++
+			original = existingSecret.DeepCopy()
+			changed, rotated = syncArgoSecret(log, &existingSecret, argoCluster, argoSecret)
+			if takeover && takeOver(&existingSecret, argoSecret) {
 				changed = true
 			}
-		}
-
-		if changed {
-			log.Info("Updating out-of-sync ArgoSecret")
-			if err := r.Update(ctx, &existingSecret); err != nil {
-				log.Error(err, "Failed to update ArgoSecret")
-				return ctrl.Result{}, err
+			if (isProtected(&existingSecret) || !EnableGarbageCollection) && dropOwnerReference(&existingSecret, source.GetUID()) {
+				log.Info("Dropping ownerReference of ArgoSecret", "protected", isProtected(&existingSecret))
+				changed = true
 			}
+			if !changed {
+				return nil
+			}
+			if r.DryRun {
+				log.Info("Dry-run, would update out-of-sync ArgoSecret", "diff", diffSummary(original, &existingSecret))
+				return nil
+			}
+			log.Info("Updating out-of-sync ArgoSecret", "diff", diffSummary(original, &existingSecret))
+			setReconciledBy(&existingSecret)
+			if DriftCorrectionMode == DriftCorrectionModePatch {
+				return r.Patch(ctx, &existingSecret, client.MergeFrom(original))
+			}
+			return r.Update(ctx, &existingSecret)
+		})
+		if err != nil {
+			if AllowRecreate && isImmutableFieldError(err) {
+				log.Info("ArgoSecret cannot be updated in-place, recreating..", "error", err.Error())
+				preserveUnmanaged(&existingSecret, argoSecret)
+				return ctrl.Result{}, r.recreate(ctx, &existingSecret, argoSecret)
+			}
+			log.Error(err, "Failed to update ArgoSecret")
+			return ctrl.Result{}, err
+		}
+		if changed && r.DryRun {
+			return ctrl.Result{}, nil
+		}
+		if changed {
+			// Counted once the update went through, so that retries are not counted twice.
+			if rotated {
+				log.Info("Bearer token rotated")
+				tokenRotationsTotal.Inc()
+			}
+			if from, to, ok := caRotation(original, argoSecret); ok {
+				log.Info("CA data rotated", "from", from, "to", to)
+				caRotationsTotal.Inc()
+			}
+			if takeover {
+				r.Recorder.Event(source, corev1.EventTypeNormal, "TakenOver",
+					fmt.Sprintf("Took over ArgoSecret %s created by another tool", argoCluster.NamespacedName))
+			}
+			secretsUpdatedTotal.Inc()
+			countRunOp(ctx, runUpdated)
 			log.Info("Updated successfully of ArgoSecret")
 			return ctrl.Result{}, nil
 		}
@@ -258,11 +924,558 @@ func (r *Capi2Argo) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resul
 	return ctrl.Result{}, nil
 }
 
+// setReconciledBy records ReconcilerIdentity on an ArgoSecret about to be written.
+func setReconciledBy(s *corev1.Secret) {
+	if ReconcilerIdentity == "" {
+		return
+	}
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[reconciledByAnnotation] = ReconcilerIdentity
+}
+
+// syncArgoSecret brings existing in-sync with the desired argoSecret, in-place. It returns
+// whether anything changed, and whether the change is a bearer token rotation.
+func syncArgoSecret(log logr.Logger, existing *corev1.Secret, argoCluster *ArgoCluster, argoSecret *corev1.Secret) (changed bool, rotated bool) {
+	if !bytes.Equal(existing.Data[ArgoNameKey], []byte(argoCluster.ClusterName)) {
+		existing.Data[ArgoNameKey] = []byte(argoCluster.ClusterName)
+		changed = true
+	}
+
+	if !sameServer(string(existing.Data[ArgoServerKey]), argoCluster.ClusterServer) {
+		existing.Data[ArgoServerKey] = []byte(argoCluster.ClusterServer)
+		changed = true
+	}
+
+	for _, key := range []string{"project", "namespaces", "clusterResources"} {
+		want, wanted := argoSecret.Data[key]
+		got, present := existing.Data[key]
+		if wanted == present && bytes.Equal(want, got) {
+			continue
+		}
+		if wanted {
+			existing.Data[key] = want
+		} else {
+			delete(existing.Data, key)
+		}
+		log.Info("Updating tenant binding of ArgoSecret", "key", key, "value", string(want))
+		changed = true
+	}
+
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+
+	// The recorded hash is trusted only as long as it still matches the stored config, so
+	// that configs edited out-of-band are detected as drift.
+	existingHash := existing.Annotations[configHashAnnotation]
+	if existingHash != "" && existingHash != configHash(existing.Data[ArgoConfigKey]) {
+		if equalIgnoringConfigFields(existing, argoSecret) {
+			log.V(1).Info("Config of ArgoSecret was modified out-of-band in ignored fields only")
+		} else {
+			log.Info("Config of ArgoSecret does not match its hash, it was modified out-of-band")
+			existingHash = ""
+		}
+	}
+	if existingHash != argoSecret.Annotations[configHashAnnotation] {
+		rotated = isTokenRotation(*existing, argoSecret)
+		existing.Data[ArgoConfigKey] = []byte(argoSecret.Data[ArgoConfigKey])
+		changed = true
+	}
+	// A rotation of the source credentials rewrites the config even when it compares equal,
+	// so that ArgoCD picks up the ArgoSecret change and reconnects.
+	if rotation, ok := argoSecret.Annotations[rotationAnnotation]; ok && existing.Annotations[rotationAnnotation] != rotation {
+		log.Info("Source of ArgoSecret was rotated, rewriting config", "rotation", rotation)
+		existing.Data[ArgoConfigKey] = []byte(argoSecret.Data[ArgoConfigKey])
+		existing.Annotations[rotationAnnotation] = rotation
+		changed = true
+	}
+	if syncKey(existing.Annotations, argoSecret.Annotations, configHashAnnotation) {
+		changed = true
+	}
+	if syncKey(existing.Annotations, argoSecret.Annotations, configEncodingAnnotation) {
+		changed = true
+	}
+
+	// Protection is only ever added, so that unprotecting takes an explicit action on the ArgoSecret.
+	if isProtected(argoSecret) && !isProtected(existing) {
+		log.Info("Protecting ArgoSecret from garbage collection")
+		existing.Annotations[protectedAnnotation] = "true"
+		changed = true
+	}
+
+	if syncKey(existing.Labels, argoSecret.Labels, clusterReadOnlyKey) {
+		log.Info("Updating readonly label of ArgoSecret", "readonly", argoSecret.Labels[clusterReadOnlyKey])
+		changed = true
+	}
+
+	// ApplicationSet labels dropped from the configuration are removed too.
+	if syncRecordedLabels(log, "ApplicationSet", existing, argoSecret, applicationSetLabelsAnnotation) {
+		changed = true
+	}
+
+	if syncKey(existing.Labels, argoSecret.Labels, clusterProviderKey) {
+		log.Info("Updating provider label of ArgoSecret", "provider", argoSecret.Labels[clusterProviderKey])
+		changed = true
+	}
+
+	if KubernetesVersionLabel != "" && syncKey(existing.Labels, argoSecret.Labels, KubernetesVersionLabel) {
+		log.Info("Updating Kubernetes version label of ArgoSecret", "version", argoSecret.Labels[KubernetesVersionLabel])
+		changed = true
+	}
+
+	if syncRecordedLabels(log, "templated", existing, argoSecret, templatedLabelsAnnotation) {
+		changed = true
+	}
+
+	// Check if take-along labels from argoCluster.TakeAlongLabels exist existing.Labels and have the same values.
+	// If not set changed to true and update existing.Labels.
+	log.V(1).Info("Checking for take-along labels")
+	log.V(1).Info("Take along labels", "labels", renderLabels(argoCluster.TakeAlongLabels))
+	if syncTakeAlong(log, "label", existing.Labels, argoCluster.TakeAlongLabels, clusterTakenFromClusterKey) {
+		changed = true
+	}
+
+	// Same as above for take-along annotations, tracked by annotationTakenFromClusterKey.
+	log.V(1).Info("Checking for take-along annotations")
+	if syncTakeAlong(log, "annotation", existing.Annotations, argoCluster.TakeAlongAnnotations, annotationTakenFromClusterKey) {
+		changed = true
+	}
+	return changed, rotated
+}
+
+// isImmutableFieldError returns true when an update was rejected for changing immutable fields.
+func isImmutableFieldError(err error) bool {
+	return errors.IsInvalid(err) && strings.Contains(err.Error(), "field is immutable")
+}
+
+// recreate replaces existing with desired, for changes that cannot be applied in-place.
+func (r *Capi2Argo) recreate(ctx context.Context, existing *corev1.Secret, desired *corev1.Secret) error {
+	log := r.Log.WithValues("cluster", client.ObjectKeyFromObject(desired))
+	if err := r.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Failed to delete ArgoSecret for recreation")
+		return err
+	}
+	secretsDeletedTotal.Inc()
+	countRunOp(ctx, runDeleted)
+	if err := r.Create(ctx, desired); err != nil {
+		log.Error(err, "Failed to recreate ArgoSecret")
+		return err
+	}
+	secretsCreatedTotal.Inc()
+	countRunOp(ctx, runCreated)
+	log.Info("Recreated successfully of ArgoSecret")
+	return nil
+}
+
+// setOutOfBandCredentials sets ArgoCluster credentials from CredentialsSecret
+// when the kubeconfig is not used as their source.
+func setOutOfBandCredentials(ctx context.Context, c client.Reader, a *ArgoCluster) error {
+	if ConfigSource != ConfigSourceServerCAOnly || a.InCluster {
+		return nil
+	}
+	var credentials corev1.Secret
+	if err := c.Get(ctx, CredentialsSecret, &credentials); err != nil {
+		return err
+	}
+	return a.SetCredentials(&credentials)
+}
+
+// setCABundle sets the ArgoCluster CA from CABundleConfigMap, when configured.
+func setCABundle(ctx context.Context, c client.Reader, a *ArgoCluster) error {
+	if CABundleConfigMap.Name == "" || a.InCluster {
+		return nil
+	}
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, CABundleConfigMap, &cm); err != nil {
+		return err
+	}
+	return a.SetCAData(cm.Data[CABundleKey], ForceCABundle)
+}
+
+// secretConfig decodes the ArgoCD cluster config stored in an ArgoSecret.
+func secretConfig(s *corev1.Secret) (ArgoConfig, error) {
+	var config ArgoConfig
+	c, err := decodeArgoConfig(s.Data[ArgoConfigKey], s.Annotations[configEncodingAnnotation])
+	if err != nil {
+		return config, err
+	}
+	return config, json.Unmarshal(c, &config)
+}
+
+// equalIgnoringConfigFields returns true when the configs of both secrets are equal once the
+// IgnoreConfigFields are removed from both.
+func equalIgnoringConfigFields(existing *corev1.Secret, desired *corev1.Secret) bool {
+	if len(IgnoreConfigFields) == 0 {
+		return false
+	}
+	var configs [2]map[string]interface{}
+	for i, s := range []*corev1.Secret{existing, desired} {
+		c, err := decodeArgoConfig(s.Data[ArgoConfigKey], s.Annotations[configEncodingAnnotation])
+		if err != nil {
+			return false
+		}
+		if err := json.Unmarshal(c, &configs[i]); err != nil {
+			return false
+		}
+		for _, field := range IgnoreConfigFields {
+			deleteConfigField(configs[i], strings.Split(field, "."))
+		}
+	}
+	return reflect.DeepEqual(configs[0], configs[1])
+}
+
+// deleteConfigField deletes the field at path from config, if present.
+func deleteConfigField(config map[string]interface{}, path []string) {
+	if len(path) == 1 {
+		delete(config, path[0])
+		return
+	}
+	if child, ok := config[path[0]].(map[string]interface{}); ok {
+		deleteConfigField(child, path[1:])
+	}
+}
+
+// isTokenRotation returns true when the configs of both secrets differ only in their bearer token.
+func isTokenRotation(existing corev1.Secret, desired *corev1.Secret) bool {
+	var configs [2]ArgoConfig
+	for i, s := range []*corev1.Secret{&existing, desired} {
+		c, err := secretConfig(s)
+		if err != nil {
+			return false
+		}
+		configs[i] = c
+	}
+	if configs[0].BearerToken == nil || configs[1].BearerToken == nil || *configs[0].BearerToken == *configs[1].BearerToken {
+		return false
+	}
+	configs[0].BearerToken, configs[1].BearerToken = nil, nil
+	return reflect.DeepEqual(configs[0], configs[1])
+}
+
+// caRotation returns the SHA-256 fingerprints of the CA data of both secrets, and whether
+// they differ. Only a CA replaced by another one is a rotation, not one added or removed
+// (eg. by toggling insecure-skip-tls-verify).
+func caRotation(existing *corev1.Secret, desired *corev1.Secret) (from string, to string, rotated bool) {
+	var fingerprints [2]string
+	for i, s := range []*corev1.Secret{existing, desired} {
+		c, err := secretConfig(s)
+		if err != nil || c.TLSClientConfig == nil || c.TLSClientConfig.CaData == nil {
+			return "", "", false
+		}
+		sum := sha256.Sum256([]byte(*c.TLSClientConfig.CaData))
+		fingerprints[i] = hex.EncodeToString(sum[:])
+	}
+	return fingerprints[0], fingerprints[1], fingerprints[0] != fingerprints[1]
+}
+
+// diffSummary lists which data keys, labels and annotations differ between two secrets,
+// as "data.<key>", "label.<key>" and "annotation.<key>". Values are never included, so
+// it is safe to log.
+func diffSummary(before *corev1.Secret, after *corev1.Secret) []string {
+	var diff []string
+	diffKeys := func(prefix string, a, b map[string]string) {
+		for k := range mergedKeys(a, b) {
+			va, inA := a[k]
+			vb, inB := b[k]
+			if inA != inB || va != vb {
+				diff = append(diff, prefix+k)
+			}
+		}
+	}
+	for k := range mergedKeys(before.Data, after.Data) {
+		va, inA := before.Data[k]
+		vb, inB := after.Data[k]
+		if inA != inB || !bytes.Equal(va, vb) {
+			diff = append(diff, "data."+k)
+		}
+	}
+	diffKeys("label.", before.Labels, after.Labels)
+	diffKeys("annotation.", before.Annotations, after.Annotations)
+	slices.Sort(diff)
+	return diff
+}
+
+// mergedKeys returns the set of keys of both maps.
+func mergedKeys[V any](a, b map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// syncKey sets or removes key on existing so that it matches desired.
+// It returns true if existing was modified.
+func syncKey(existing map[string]string, desired map[string]string, key string) bool {
+	want, wanted := desired[key]
+	got, present := existing[key]
+	if want == got && wanted == present {
+		return false
+	}
+	if wanted {
+		existing[key] = want
+	} else {
+		delete(existing, key)
+	}
+	return true
+}
+
+// recordLabels records keys, the labels of s set from a source outside of CACO (eg. the spec
+// of a ClusterRegistration), in its annotation, see syncRecordedLabels.
+func recordLabels(s *corev1.Secret, annotation string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[annotation] = strings.Join(slices.Sorted(slices.Values(keys)), ",")
+}
+
+// syncRecordedLabels updates existing in-place to match the labels of desired recorded by
+// annotation, see recordLabels. The annotation of existing acts as bookkeeping, so labels
+// dropped from their source since the last sync are removed too. It returns true if existing
+// was modified.
+func syncRecordedLabels(log logr.Logger, kind string, existing *corev1.Secret, desired *corev1.Secret, annotation string) bool {
+	changed := false
+	previous := strings.Split(existing.Annotations[annotation], ",")
+	current := strings.Split(desired.Annotations[annotation], ",")
+	for _, key := range slices.Compact(slices.Sorted(slices.Values(append(previous, current...)))) {
+		if key == "" || !syncKey(existing.Labels, desired.Labels, key) {
+			continue
+		}
+		if _, ok := desired.Labels[key]; ok {
+			log.Info("Updating "+kind+" label of ArgoSecret", "label", key, "value", desired.Labels[key])
+		} else {
+			log.Info("Removing stale "+kind+" label from ArgoSecret", "label", key)
+		}
+		changed = true
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	if syncKey(existing.Annotations, desired.Annotations, annotation) {
+		changed = true
+	}
+	return changed
+}
+
+// preserveUnmanaged copies the labels and annotations of existing that are not managed by
+// CACO (eg. set by an admin) over to desired, so that replacing existing keeps them.
+func preserveUnmanaged(existing *corev1.Secret, desired *corev1.Secret) {
+	copyUnmanaged := func(from map[string]string, to map[string]string, takenKey string) map[string]string {
+		for k, v := range from {
+			if _, ok := to[k]; ok || isManagedKey(k, from, takenKey) {
+				continue
+			}
+			if to == nil {
+				to = map[string]string{}
+			}
+			to[k] = v
+		}
+		return to
+	}
+	desired.Labels = copyUnmanaged(existing.Labels, desired.Labels, clusterTakenFromClusterKey)
+	desired.Annotations = copyUnmanaged(existing.Annotations, desired.Annotations, annotationTakenFromClusterKey)
+}
+
+// isManagedKey returns true for the label or annotation keys of an ArgoSecret that CACO
+// manages: the ones prefixed with managedKeyPrefix, the ArgoCD secret-type label and the
+// take-along keys, tracked through takenKey in existing.
+func isManagedKey(key string, existing map[string]string, takenKey string) bool {
+	if strings.HasPrefix(key, managedKeyPrefix) || key == "argocd.argoproj.io/secret-type" || strings.HasPrefix(key, takenKey) {
+		return true
+	}
+	_, takenAlong := existing[takenKey+key]
+	return takenAlong
+}
+
+// syncTakeAlong updates existing in-place to match desired take-along keys. Keys prefixed
+// with takenKey act as bookkeeping, so keys removed from the cluster resource are removed too.
+// It returns true if existing was modified.
+func syncTakeAlong(log logr.Logger, kind string, existing map[string]string, desired map[string]string, takenKey string) bool {
+	changed := false
+
+	desiredTakenAlong := []string{}
+	for k := range desired {
+		if strings.HasPrefix(k, takenKey) {
+			key := strings.Split(k, takenKey)[1]
+			desiredTakenAlong = append(desiredTakenAlong, key)
+		}
+	}
+	// Find difference between keys prefixed with takenKey between existing
+	// and desired in order to handle removed 'take-from' keys from the cluster resource
+	for k := range existing {
+		if strings.HasPrefix(k, takenKey) {
+			key := strings.Split(k, takenKey)[1]
+			if !slices.Contains(desiredTakenAlong, key) {
+				log.Info("Removing stale "+kind+" from ArgoSecret", kind, key)
+				delete(existing, k)
+				delete(existing, key)
+				changed = true
+			}
+		}
+	}
+
+	// Update existing with current values
+	for k, v := range desired {
+		// check if key exists in map
+		if val, ok := existing[k]; ok {
+			// check if value is the same
+			if val != v {
+				log.Info("Updating value of "+kind+" in ArgoSecret", kind, k, "value", val)
+				existing[k] = v
+				changed = true
+			}
+		} else {
+			log.Info("Adding missing "+kind+" in ArgoSecret", kind, k)
+			existing[k] = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+// CacheOptions returns the manager cache options, resyncing every watched object each
+// syncPeriod so that drifted ArgoSecrets are corrected without waiting for an event. The cache
+// is restricted to SingleNamespace, when set, its Namespaces to OperatorNamespace, and its
+// ConfigMaps to the ones read, unless kubeconfig ConfigMaps are watched.
+func CacheOptions(syncPeriod time.Duration) cache.Options {
+	opts := cache.Options{SyncPeriod: &syncPeriod}
+	if SingleNamespace != "" {
+		opts.DefaultNamespaces = map[string]cache.Config{SingleNamespace: {}}
+	}
+	if OperatorNamespace != "" {
+		opts.ByObject = pauseCacheByObject()
+	}
+	if !WatchConfigMaps && (RuntimeConfigMap.Name != "" || CABundleConfigMap.Name != "") {
+		if opts.ByObject == nil {
+			opts.ByObject = map[client.Object]cache.ByObject{}
+		}
+		opts.ByObject[&corev1.ConfigMap{}] = configMapCacheByObject()
+	}
+	return opts
+}
+
 // SetupWithManager ..
 func (r *Capi2Argo) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.echoes = &sync.Map{}
+	r.owners = &sync.Map{}
+	r.gcPending = &sync.Map{}
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Secret{}).
-		Complete(r)
+		// Cluster changes (eg. upgrades, take-along labels) re-trigger their kubeconfig secret.
+		Watches(&clusterv1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.forgetEchoes(clusterToCapiSecret))).
+		WithOptions(controller.Options{RateLimiter: newRateLimiter()})
+	if r.Resync != nil {
+		b = b.WatchesRawSource(source.Channel(r.Resync, handler.EnqueueRequestsFromMapFunc(r.forgetEchoes(objectToRequest))))
+	}
+	return b.Complete(r)
+}
+
+// capiSecretClusterName returns the name of the Cluster of a CapiSecret. Secrets not created
+// by CAPI (eg. synced by External Secrets Operator) may miss the cluster-name label, and
+// fall back to their <clusterName>-kubeconfig name.
+func capiSecretClusterName(s *corev1.Secret) string {
+	if name, ok := s.Labels[clusterv1.ClusterNameLabel]; ok {
+		return name
+	}
+	return strings.TrimSuffix(s.Name, "-kubeconfig")
+}
+
+// clusterOfSource returns the Cluster named after the <clusterName>-kubeconfig source nn.
+func clusterOfSource(nn types.NamespacedName) types.NamespacedName {
+	return types.NamespacedName{Name: strings.TrimSuffix(nn.Name, "-kubeconfig"), Namespace: nn.Namespace}
+}
+
+// clusterToCapiSecret maps a Cluster to the request of its <clusterName>-kubeconfig secret.
+func clusterToCapiSecret(_ context.Context, o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      o.GetName() + "-kubeconfig",
+		Namespace: o.GetNamespace(),
+	}}}
+}
+
+// ValidateArgoSecretSource checks whether an existing ArgoSecret was generated from the
+// same CAPI secret as the desired one.
+func ValidateArgoSecretSource(existing corev1.Secret, desired *corev1.Secret) error {
+	for _, l := range []string{"capi-to-argocd/cluster-secret-name", "capi-to-argocd/cluster-namespace"} {
+		if existing.Labels[l] != desired.Labels[l] {
+			return fmt.Errorf("%w: %s is generated from %s/%s", ErrArgoSecretNameCollision, existing.Name,
+				existing.Labels["capi-to-argocd/cluster-namespace"], existing.Labels["capi-to-argocd/cluster-secret-name"])
+		}
+	}
+	return nil
+}
+
+// ValidateWatchNamespace returns true when namespace matches WatchNamespaces, or none are set.
+// Malformed patterns never match, see ValidateWatchNamespacePatterns.
+func ValidateWatchNamespace(namespace string) bool {
+	if len(WatchNamespaces) == 0 {
+		return true
+	}
+	for _, pattern := range WatchNamespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWatchNamespacePatterns checks that all patterns are well-formed path.Match globs.
+func ValidateWatchNamespacePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid watch namespace pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// ValidateSingleNamespace checks that all given namespaces are SingleNamespace, when set.
+func ValidateSingleNamespace(namespaces ...string) error {
+	if SingleNamespace == "" {
+		return nil
+	}
+	for _, ns := range namespaces {
+		if ns != SingleNamespace {
+			return fmt.Errorf("%w: %s", ErrCrossNamespace, ns)
+		}
+	}
+	return nil
+}
+
+// ValidateArgoNamespaceSettings checks argoNamespace against ShadowNamespace and
+// SingleNamespace, both at startup and whenever the ArgoCD namespace changes at runtime.
+func ValidateArgoNamespaceSettings(argoNamespace string) error {
+	if ShadowNamespace != "" && (ShadowNamespace == argoNamespace || SingleNamespace != "") {
+		return fmt.Errorf("%w: %s", ErrShadowNamespace, ShadowNamespace)
+	}
+	return ValidateSingleNamespace(argoNamespace)
+}
+
+// canTakeOver returns true when TakeoverSecretType is set and s, not owned by CACO, is an
+// ArgoCD cluster secret. Other ArgoCD secrets, eg. repositories, are never taken over.
+func canTakeOver(s corev1.Secret) bool {
+	return TakeoverSecretType && s.Labels["argocd.argoproj.io/secret-type"] == "cluster"
+}
+
+// takeOver marks existing as owned by CACO and generated from the source of desired, so that
+// it is reconciled and garbage collected like the ArgoSecrets CACO creates. It returns true if
+// existing was modified.
+func takeOver(existing *corev1.Secret, desired *corev1.Secret) bool {
+	changed := false
+	for _, l := range []string{"capi-to-argocd/owned", "capi-to-argocd/cluster-secret-name", "capi-to-argocd/cluster-namespace"} {
+		if syncKey(existing.Labels, desired.Labels, l) {
+			changed = true
+		}
+	}
+	return changed
 }
 
 // ValidateObjectOwner checks whether reconciled object is managed by CACO or not.
@@ -1,21 +1,36 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"maps"
+	"regexp"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 )
@@ -53,9 +68,10 @@ var _ = ginkgo.BeforeSuite(func() {
 	gomega.Expect(err).ToNot(gomega.HaveOccurred())
 
 	C2A = &Capi2Argo{
-		Client: K8sManager.GetClient(),
-		Log:    TestLog,
-		Scheme: K8sManager.GetScheme(),
+		Client:   K8sManager.GetClient(),
+		Log:      TestLog,
+		Scheme:   K8sManager.GetScheme(),
+		Recorder: K8sManager.GetEventRecorderFor("capi2argo"),
 	}
 	err = C2A.SetupWithManager(K8sManager)
 	gomega.Expect(err).ToNot(gomega.HaveOccurred())
@@ -135,6 +151,46 @@ func TestReconcile(t *testing.T) {
 	})
 }
 
+// TestReconcileExistingArgoSecret processes a CAPI secret whose ArgoSecret already exists.
+func TestReconcileExistingArgoSecret(t *testing.T) {
+	t.Parallel()
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	AssertReconcileIdempotent(t, c, r, req)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+// mockT records the failures of assertions.
+type mockT struct {
+	failed bool
+}
+
+func (m *mockT) Errorf(string, ...interface{}) { m.failed = true }
+
+func TestAssertReconcileIdempotent(t *testing.T) {
+	t.Parallel()
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	assert.True(t, AssertReconcileIdempotent(t, c, r, req))
+
+	// Every pass rewriting the source is not idempotent.
+	rewrite := reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		s := &corev1.Secret{}
+		if err := c.Get(ctx, req.NamespacedName, s); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, c.Update(ctx, s)
+	})
+	m := &mockT{}
+	assert.False(t, AssertReconcileIdempotent(m, c, rewrite, req))
+	assert.True(t, m.failed)
+
+	// Neither is a failing reconcile.
+	m = &mockT{}
+	assert.False(t, AssertReconcileIdempotent(m, c, rewrite, MockReconcileReq("missing-kubeconfig", TestNamespace)))
+	assert.True(t, m.failed)
+}
+
 func TestValidateObjectOwner(t *testing.T) {
 	var o corev1.Secret
 
@@ -151,6 +207,550 @@ func TestValidateObjectOwner(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestReconcileTakeAlongAnnotations(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{
+		"foo": "bar",
+		fmt.Sprintf("%s%s", clusterTakeAlongAnnotationKey, "foo"): "",
+	})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	takenKey := fmt.Sprintf("%s%s", annotationTakenFromClusterKey, "foo")
+
+	// Adding a take-along annotation propagates it on creation.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "bar", argoSecret.Annotations["foo"])
+	assert.Contains(t, argoSecret.Annotations, takenKey)
+
+	// Changing the annotation value on the cluster updates the secret.
+	cluster.Annotations["foo"] = "baz"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "baz", argoSecret.Annotations["foo"])
+
+	// Removing the take-along annotation drops both the value and the bookkeeping key.
+	delete(cluster.Annotations, fmt.Sprintf("%s%s", clusterTakeAlongAnnotationKey, "foo"))
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Annotations, "foo")
+	assert.NotContains(t, argoSecret.Annotations, takenKey)
+}
+
+func TestReconcileReadOnlyLabel(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterReadOnlyKey: "true"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "true", argoSecret.Labels[clusterReadOnlyKey])
+
+	// Removing the annotation from the cluster drops the label.
+	delete(cluster.Annotations, clusterReadOnlyKey)
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Labels, clusterReadOnlyKey)
+}
+
+func TestReconcileProviderLabel(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, nil)
+	cluster.Spec.InfrastructureRef = &corev1.ObjectReference{Kind: "AWSCluster", Name: "test"}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "AWSCluster", argoSecret.Labels[clusterProviderKey])
+
+	// Changing the infrastructure provider updates the label.
+	cluster.Spec.InfrastructureRef.Kind = "GCPCluster"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "GCPCluster", argoSecret.Labels[clusterProviderKey])
+}
+
+func TestReconcileKubernetesVersionLabel(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, nil)
+	cluster.Spec.Topology = &clusterv1.Topology{Class: "test", Version: "v1.30.2"}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "v1.30.2", argoSecret.Labels[KubernetesVersionLabel])
+
+	// Upgrading the cluster updates the label.
+	cluster.Spec.Topology.Version = "v1.31.0+rke2r1"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "v1.31.0_rke2r1", argoSecret.Labels[KubernetesVersionLabel])
+
+	// Clusters without a topology carry the version of their control plane, the running
+	// one first.
+	controlPlane := &unstructured.Unstructured{}
+	controlPlane.SetAPIVersion("controlplane.cluster.x-k8s.io/v1beta1")
+	controlPlane.SetKind("KubeadmControlPlane")
+	controlPlane.SetName("test-control-plane")
+	controlPlane.SetNamespace(TestNamespace)
+	assert.Nil(t, unstructured.SetNestedField(controlPlane.Object, "v1.31.1", "spec", "version"))
+	assert.Nil(t, c.Create(context.Background(), controlPlane))
+	cluster.Spec.Topology = nil
+	cluster.Spec.ControlPlaneRef = &corev1.ObjectReference{
+		APIVersion: "controlplane.cluster.x-k8s.io/v1beta1",
+		Kind:       "KubeadmControlPlane",
+		Name:       "test-control-plane",
+	}
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "v1.31.1", argoSecret.Labels[KubernetesVersionLabel])
+
+	assert.Nil(t, unstructured.SetNestedField(controlPlane.Object, "v1.31.0", "status", "version"))
+	assert.Nil(t, c.Update(context.Background(), controlPlane))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "v1.31.0", argoSecret.Labels[KubernetesVersionLabel])
+	AssertReconcileIdempotent(t, c, r, req)
+
+	// Clusters without a topology nor a control plane carry no version.
+	cluster.Spec.ControlPlaneRef = nil
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Labels, KubernetesVersionLabel)
+}
+
+func TestClusterToCapiSecret(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []reconcile.Request{MockReconcileReq("test-kubeconfig", TestNamespace)},
+		clusterToCapiSecret(context.Background(), MockCluster("test", TestNamespace, nil, nil)))
+}
+
+func TestReconcileExternalSecret(t *testing.T) {
+	oldConf := ExtraOwnerLabels
+	ExtraOwnerLabels = map[string]string{"reconcile.external-secrets.io/managed": "true"}
+	defer func() { ExtraOwnerLabels = oldConf }()
+
+	r, c := MockReconciler(MockExternalSecret("eso-kubeconfig", TestNamespace))
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("eso-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-eso", Namespace: ArgoNamespace}, argoSecret))
+	assert.Equal(t, "eso-kubeconfig", argoSecret.Labels["capi-to-argocd/cluster-secret-name"])
+}
+
+func TestReconcileNameCollision(t *testing.T) {
+	r, c := MockReconciler(
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", "team-a"),
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", "team-b"),
+	)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	collisions := MockCounterValue(argoSecretNameCollisionsTotal)
+
+	// First writer creates the ArgoSecret, the second one collides on every attempt.
+	for _, ns := range []string{"team-a", "team-b", "team-b", "team-a"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", ns))
+		assert.Nil(t, err)
+		argoSecret := &corev1.Secret{}
+		assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+		assert.Equal(t, "team-a", argoSecret.Labels["capi-to-argocd/cluster-namespace"])
+	}
+	assert.Equal(t, collisions+2, MockCounterValue(argoSecretNameCollisionsTotal))
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Len(t, recorder.Events, 2)
+	assert.Contains(t, <-recorder.Events, "Warning NameCollision")
+}
+
+func TestReconcileRequiredSourceLabels(t *testing.T) {
+	oldConf := RequiredSourceLabels
+	defer func() { RequiredSourceLabels = oldConf }()
+	RequiredSourceLabels = []string{"environment", "team"}
+
+	missing := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	missing.Labels["team"] = "platform"
+	labeled := MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace)
+	labeled.Labels["environment"] = "prod"
+	labeled.Labels["team"] = "platform"
+	r, c := MockReconciler(missing, labeled)
+
+	// Secrets missing required labels are skipped with a Warning.
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Equal(t, "Warning MissingRequiredLabels Missing required labels: environment", <-recorder.Events)
+
+	// Secrets carrying them are synced.
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("other-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcileSelfRegistration(t *testing.T) {
+	oldSelf, oldAllow := SelfServer, AllowSelfRegistration
+	defer func() { SelfServer, AllowSelfRegistration = oldSelf, oldAllow }()
+	SelfServer = "https://KUBE-CLUSTER-TEST.domain.com:6443"
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// The cluster CACO runs in is skipped with a Warning.
+	AllowSelfRegistration = false
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning SelfRegistration")
+
+	// Unless explicitly allowed.
+	AllowSelfRegistration = true
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestReconcileRegisterAfter(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	capiSecret.CreationTimestamp = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterRegisterAfterKey: "1h"})
+	r, c := MockReconciler(capiSecret, cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// Not yet elapsed, registration is requeued for the remaining time.
+	result, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Greater(t, result.RequeueAfter, 49*time.Minute)
+	assert.LessOrEqual(t, result.RequeueAfter, 50*time.Minute)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+
+	// Elapsed, the cluster is registered.
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, capiSecret))
+	capiSecret.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	result, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// Invalid delays fail the sync.
+	for _, invalid := range []string{"soon", "-1h"} {
+		cluster.Annotations[clusterRegisterAfterKey] = invalid
+		_, err := NewArgoCluster(MockCapiCluster("test", TestNamespace), capiSecret, cluster)
+		assert.ErrorContains(t, err, "invalid "+clusterRegisterAfterKey)
+	}
+}
+
+func TestReconcileLoopbackServer(t *testing.T) {
+	oldConf := RejectLoopbackServers
+	defer func() { RejectLoopbackServers = oldConf }()
+	RejectLoopbackServers = true
+
+	loopback := MockCapiSecret(validMock, validType, validKey, "loopback-kubeconfig", TestNamespace)
+	loopback.Labels[clusterv1.ClusterNameLabel] = "loopback"
+	loopback.Data["value"] = bytes.Replace(loopback.Data["value"],
+		[]byte("https://kube-cluster-test.domain.com:6443"), []byte("https://127.0.0.1:6443"), 1)
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), loopback)
+	loopbackKey := types.NamespacedName{Name: "cluster-loopback", Namespace: ArgoNamespace}
+	skipped := MockCounterValue(loopbackServersTotal)
+
+	// Only the loopback server is skipped, with a Warning.
+	for _, name := range []string{"test-kubeconfig", "loopback-kubeconfig"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name, TestNamespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), loopbackKey, &corev1.Secret{})))
+	assert.Equal(t, skipped+1, MockCounterValue(loopbackServersTotal))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning LoopbackServer Server https://127.0.0.1:6443")
+
+	// Unless explicitly allowed.
+	RejectLoopbackServers = false
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("loopback-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), loopbackKey, &corev1.Secret{}))
+	assert.Equal(t, skipped+1, MockCounterValue(loopbackServersTotal))
+}
+
+func TestIsLoopbackServer(t *testing.T) {
+	t.Parallel()
+	for _, server := range []string{
+		"https://127.0.0.1:6443", "https://127.1.2.3", "https://[::1]:6443",
+		"https://localhost:6443", "https://LOCALHOST.", "https://api.localhost:6443",
+	} {
+		assert.True(t, isLoopbackServer(server), server)
+	}
+	for _, server := range []string{
+		"https://kube-cluster-test.domain.com:6443", "https://10.0.0.1:6443", "https://[::2]:6443",
+		"https://localhost.domain.com", "https://kubernetes.default.svc", "", "://invalid",
+	} {
+		assert.False(t, isLoopbackServer(server), server)
+	}
+}
+
+func TestReconcileDuplicateServer(t *testing.T) {
+	oldConf := SkipDuplicateServers
+	defer func() { SkipDuplicateServers = oldConf }()
+
+	// Both mocks share the server of the mock kubeconfig.
+	other := MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace)
+	other.Labels[clusterv1.ClusterNameLabel] = "other"
+	newcomer := MockCapiSecret(validMock, validType, validKey, "newcomer-kubeconfig", TestNamespace)
+	newcomer.Labels[clusterv1.ClusterNameLabel] = "newcomer"
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), other, newcomer)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	duplicates := MockCounterValue(duplicateServersTotal)
+
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Len(t, recorder.Events, 0)
+
+	// A duplicate is registered with a Warning by default.
+	SkipDuplicateServers = false
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("other-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{}))
+	assert.Equal(t, "Warning DuplicateServer Server https://kube-cluster-test.domain.com:6443 is already registered by ArgoSecret argocd/cluster-test", <-recorder.Events)
+	assert.Equal(t, duplicates+1, MockCounterValue(duplicateServersTotal))
+
+	// With SkipDuplicateServers, newcomers are skipped but registered duplicates kept in-sync.
+	SkipDuplicateServers = true
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("newcomer-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-newcomer", Namespace: ArgoNamespace}, &corev1.Secret{})))
+	assert.Contains(t, <-recorder.Events, "Warning DuplicateServer")
+
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("other-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{}))
+	assert.Contains(t, <-recorder.Events, "Warning DuplicateServer")
+	assert.Equal(t, duplicates+3, MockCounterValue(duplicateServersTotal))
+}
+
+func TestIsSelfServer(t *testing.T) {
+	oldConf := SelfServer
+	defer func() { SelfServer = oldConf }()
+	SelfServer = "https://10.96.0.1:443"
+
+	assert.True(t, isSelfServer("https://10.96.0.1"))
+	assert.True(t, isSelfServer("https://10.96.0.1:443/"))
+	assert.True(t, isSelfServer("https://kubernetes.default.svc"))
+	assert.True(t, isSelfServer("https://kubernetes.default.svc:443"))
+	assert.False(t, isSelfServer("https://10.96.0.1:6443"))
+	assert.False(t, isSelfServer("https://kube-cluster-test.domain.com:6443"))
+	assert.False(t, isSelfServer(""))
+
+	SelfServer = ""
+	assert.False(t, isSelfServer("https://10.96.0.1"))
+}
+
+func TestValidateArgoSecretSource(t *testing.T) {
+	t.Parallel()
+	existing := MockArgoSecret()
+	assert.Nil(t, ValidateArgoSecretSource(*existing, MockArgoSecret()))
+
+	desired := MockArgoSecret()
+	desired.Labels["capi-to-argocd/cluster-namespace"] = "other"
+	err := ValidateArgoSecretSource(*existing, desired)
+	assert.ErrorIs(t, err, ErrArgoSecretNameCollision)
+}
+
+func TestReconcileTokenRotation(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	rotations := MockCounterValue(tokenRotationsTotal)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, rotations+1, MockCounterValue(tokenRotationsTotal))
+
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), `"bearerToken":"rotated"`)
+
+	// Reconciling an in-sync secret is not a rotation.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, rotations+1, MockCounterValue(tokenRotationsTotal))
+}
+
+func TestReconcileCARotation(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	rotations := MockCounterValue(caRotationsTotal)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// A token rotation is not a CA rotation.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, rotations, MockCounterValue(caRotationsTotal))
+
+	capiSecret.Data["value"] = regexp.MustCompile(`certificate-authority-data: \S+`).
+		ReplaceAll(capiSecret.Data["value"], []byte("certificate-authority-data: dGVzdGVyCg=="))
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, rotations+1, MockCounterValue(caRotationsTotal))
+
+	// Reconciling an in-sync secret is not a rotation.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, rotations+1, MockCounterValue(caRotationsTotal))
+}
+
+func TestCARotation(t *testing.T) {
+	t.Parallel()
+	withCA := func(ca *string) *corev1.Secret {
+		a := MockArgoCluster(validMock)
+		a.ClusterConfig.TLSClientConfig.CaData = ca
+		s, _ := a.ConvertToSecret()
+		return s
+	}
+	ca, other := "ca", "other"
+	tests := []struct {
+		testName            string
+		testExisting        *corev1.Secret
+		testDesired         *corev1.Secret
+		testExpectedRotated bool
+	}{
+		{"test same ca", withCA(&ca), withCA(&ca), false},
+		{"test ca change", withCA(&ca), withCA(&other), true},
+		{"test ca added", withCA(nil), withCA(&ca), false},
+		{"test ca removed", withCA(&ca), withCA(nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			from, to, rotated := caRotation(tt.testExisting, tt.testDesired)
+			assert.Equal(t, tt.testExpectedRotated, rotated)
+			if rotated {
+				assert.NotEqual(t, from, to)
+				assert.Len(t, to, 64)
+			}
+		})
+	}
+}
+
+func TestIsTokenRotation(t *testing.T) {
+	t.Parallel()
+	withToken := func(token string, ca string) *corev1.Secret {
+		a := MockArgoCluster(validMock)
+		a.ClusterConfig.BearerToken = &token
+		a.ClusterConfig.TLSClientConfig.CaData = &ca
+		s, _ := a.ConvertToSecret()
+		return s
+	}
+	tests := []struct {
+		testName         string
+		testExisting     *corev1.Secret
+		testDesired      *corev1.Secret
+		testExpectedSync bool
+	}{
+		{"test same config", withToken("a", "ca"), withToken("a", "ca"), false},
+		{"test token-only change", withToken("a", "ca"), withToken("b", "ca"), true},
+		{"test token and ca change", withToken("a", "ca"), withToken("b", "other"), false},
+		{"test ca-only change", withToken("a", "ca"), withToken("a", "other"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.testExpectedSync, isTokenRotation(*tt.testExisting, tt.testDesired))
+		})
+	}
+}
+
+func TestReconcileServerCAOnly(t *testing.T) {
+	oldSource, oldSecret := ConfigSource, CredentialsSecret
+	ConfigSource = ConfigSourceServerCAOnly
+	CredentialsSecret = types.NamespacedName{Name: "argocd-sa", Namespace: ArgoNamespace}
+	defer func() { ConfigSource, CredentialsSecret = oldSource, oldSecret }()
+
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: CredentialsSecret.Name, Namespace: CredentialsSecret.Namespace},
+		Data:       map[string][]byte{"bearerToken": []byte("shared")},
+	}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), credentials)
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, argoSecret))
+	var config ArgoConfig
+	assert.Nil(t, json.Unmarshal(argoSecret.Data["config"], &config))
+	assert.Equal(t, "shared", *config.BearerToken)
+	assert.NotEmpty(t, *config.TLSClientConfig.CaData)
+
+	// A missing credentials secret fails the reconcile.
+	r, _ = MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.NotNil(t, err)
+}
+
+func TestSyncKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		testName         string
+		testExisting     map[string]string
+		testDesired      map[string]string
+		testExpectedSync bool
+	}{
+		{"test key in-sync", map[string]string{"k": "v"}, map[string]string{"k": "v"}, false},
+		{"test key absent on both", map[string]string{}, map[string]string{}, false},
+		{"test key missing", map[string]string{}, map[string]string{"k": "v"}, true},
+		{"test key changed", map[string]string{"k": "v"}, map[string]string{"k": "w"}, true},
+		{"test key removed", map[string]string{"k": "v"}, map[string]string{}, true},
+		{"test key with empty value missing", map[string]string{}, map[string]string{"k": ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.testExpectedSync, syncKey(tt.testExisting, tt.testDesired, "k"))
+			assert.Equal(t, tt.testDesired, tt.testExisting)
+		})
+	}
+}
+
 func MockReconcileReq(name string, namespace string) reconcile.Request {
 	r := reconcile.Request{
 		NamespacedName: types.NamespacedName{
@@ -185,3 +785,1870 @@ func MockReconcileEnv() error {
 
 	return K8sClient.Create(context.Background(), MockCapiSecret(validMock, validType, !validKey, "err-key-kubeconfig", TestNamespace))
 }
+
+func MockImmutableError(name string) error {
+	return apierrors.NewInvalid(schema.GroupKind{Kind: "Secret"}, name, field.ErrorList{
+		field.Invalid(field.NewPath("data"), nil, "field is immutable when `immutable` is set"),
+	})
+}
+
+func TestReconcileAllowRecreate(t *testing.T) {
+	oldConf := AllowRecreate
+	defer func() { AllowRecreate = oldConf }()
+
+	for _, allow := range []bool{false, true} {
+		AllowRecreate = allow
+		capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+		r, c := MockReconciler(capiSecret)
+		req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+		_, err := r.Reconcile(context.Background(), req)
+		assert.Nil(t, err)
+		argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+		existing := &corev1.Secret{}
+		assert.Nil(t, c.Get(context.Background(), argoKey, existing))
+
+		c.OnUpdate = func(obj client.Object) error {
+			if obj.GetNamespace() == ArgoNamespace {
+				return MockImmutableError(obj.GetName())
+			}
+			return nil
+		}
+		created, deleted := MockCounterValue(secretsCreatedTotal), MockCounterValue(secretsDeletedTotal)
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+		capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+		assert.Nil(t, c.Update(context.Background(), capiSecret))
+
+		_, err = r.Reconcile(context.Background(), req)
+		argoSecret := &corev1.Secret{}
+		assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+		if !allow {
+			assert.True(t, isImmutableFieldError(err))
+			assert.Equal(t, existing.Data, argoSecret.Data)
+			assert.Equal(t, created, MockCounterValue(secretsCreatedTotal))
+			assert.Equal(t, deleted, MockCounterValue(secretsDeletedTotal))
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Contains(t, string(argoSecret.Data["config"]), `"bearerToken":"rotated"`)
+		assert.Equal(t, created+1, MockCounterValue(secretsCreatedTotal))
+		assert.Equal(t, deleted+1, MockCounterValue(secretsDeletedTotal))
+	}
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		testName          string
+		testMock          error
+		testExpectedValue bool
+	}{
+		{"test nil error", nil, false},
+		{"test immutable error", MockImmutableError("test"), true},
+		{"test other invalid error", apierrors.NewInvalid(schema.GroupKind{Kind: "Secret"}, "test", field.ErrorList{
+			field.Required(field.NewPath("data"), ""),
+		}), false},
+		{"test conflict error", apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "test", fmt.Errorf("conflict")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.testExpectedValue, isImmutableFieldError(tt.testMock))
+		})
+	}
+}
+
+func TestReconcileConcurrentCreate(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+	// Another writer creates a stale copy of the ArgoSecret right before we do.
+	c.OnCreate = func(obj client.Object) error {
+		c.OnCreate = nil
+		stale := obj.DeepCopyObject().(*corev1.Secret)
+		stale.Data["server"] = []byte("https://stale:6443")
+		return c.Create(context.Background(), stale)
+	}
+	created := MockCounterValue(secretsCreatedTotal)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, created, MockCounterValue(secretsCreatedTotal))
+
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, argoSecret))
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["server"]))
+}
+
+func TestCacheOptions(t *testing.T) {
+	oldConf, oldOperatorNamespace := SingleNamespace, OperatorNamespace
+	defer func() { SingleNamespace, OperatorNamespace = oldConf, oldOperatorNamespace }()
+	oldConfigMap, oldCABundle, oldWatch := RuntimeConfigMap, CABundleConfigMap, WatchConfigMaps
+	defer func() { RuntimeConfigMap, CABundleConfigMap, WatchConfigMaps = oldConfigMap, oldCABundle, oldWatch }()
+
+	SingleNamespace, OperatorNamespace = "", ""
+	opts := CacheOptions(10 * time.Minute)
+	assert.Equal(t, 10*time.Minute, *opts.SyncPeriod)
+	assert.Nil(t, opts.DefaultNamespaces)
+	assert.Nil(t, opts.ByObject)
+
+	// Only the operator namespace is cached, for pausing.
+	OperatorNamespace = "caco-system"
+	opts = CacheOptions(10 * time.Minute)
+	for obj, byObject := range opts.ByObject {
+		assert.IsType(t, &corev1.Namespace{}, obj)
+		assert.Equal(t, "metadata.name=caco-system", byObject.Field.String())
+	}
+	assert.Len(t, opts.ByObject, 1)
+
+	SingleNamespace = "capi"
+	opts = CacheOptions(45 * time.Second)
+	assert.Equal(t, 45*time.Second, *opts.SyncPeriod)
+	assert.Contains(t, opts.DefaultNamespaces, "capi")
+
+	// Only the ConfigMaps read are cached, unless kubeconfig ConfigMaps are watched.
+	SingleNamespace, OperatorNamespace = "", ""
+	RuntimeConfigMap = types.NamespacedName{Namespace: "caco-system", Name: "caco-config"}
+	CABundleConfigMap = types.NamespacedName{Namespace: "cert-manager", Name: "trust-bundle"}
+	opts = CacheOptions(10 * time.Minute)
+	assert.Len(t, opts.ByObject, 1)
+	for obj, byObject := range opts.ByObject {
+		assert.IsType(t, &corev1.ConfigMap{}, obj)
+		assert.Len(t, byObject.Namespaces, 2)
+		assert.Equal(t, "metadata.name=caco-config", byObject.Namespaces["caco-system"].FieldSelector.String())
+		assert.Equal(t, "metadata.name=trust-bundle", byObject.Namespaces["cert-manager"].FieldSelector.String())
+	}
+
+	CABundleConfigMap.Namespace = "caco-system"
+	opts = CacheOptions(10 * time.Minute)
+	for _, byObject := range opts.ByObject {
+		assert.Equal(t, map[string]cache.Config{"caco-system": {}}, byObject.Namespaces)
+	}
+
+	WatchConfigMaps = true
+	opts = CacheOptions(10 * time.Minute)
+	assert.Nil(t, opts.ByObject)
+}
+
+func TestReconcileSingleNamespace(t *testing.T) {
+	oldConf := SingleNamespace
+	defer func() { SingleNamespace = oldConf }()
+	SingleNamespace = ArgoNamespace
+
+	r, c := MockReconciler(
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", ArgoNamespace),
+		MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace),
+	)
+
+	// Sources in the single namespace are synced.
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", ArgoNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+
+	// Sources outside of it are rejected.
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("other-kubeconfig", TestNamespace))
+	assert.ErrorIs(t, err, ErrCrossNamespace)
+	assert.NotNil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcileOwnerReferenceGC(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	sameNamespace := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", ArgoNamespace)
+	sameNamespace.UID = "same-uid"
+	crossNamespace := MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace)
+	r, c := MockReconciler(sameNamespace, crossNamespace)
+	r.owners = &sync.Map{}
+	sameKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	crossKey := types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}
+
+	for _, s := range []*corev1.Secret{sameNamespace, crossNamespace} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+		assert.Nil(t, err)
+	}
+
+	// Same-namespace ArgoSecrets are owned by their source.
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), sameKey, argoSecret))
+	if assert.Len(t, argoSecret.OwnerReferences, 1) {
+		assert.Equal(t, "Secret", argoSecret.OwnerReferences[0].Kind)
+		assert.Equal(t, "test-kubeconfig", argoSecret.OwnerReferences[0].Name)
+		assert.Equal(t, types.UID("same-uid"), argoSecret.OwnerReferences[0].UID)
+	}
+	assert.Nil(t, c.Get(context.Background(), crossKey, argoSecret))
+	assert.Empty(t, argoSecret.OwnerReferences)
+
+	// On deletion, owned ArgoSecrets are left to Kubernetes and others are collected by label.
+	for _, s := range []*corev1.Secret{sameNamespace, crossNamespace} {
+		assert.Nil(t, c.Delete(context.Background(), s))
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), sameKey, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), crossKey, &corev1.Secret{})))
+}
+
+func TestReconcileOwnerReferenceWithoutGC(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = false
+
+	source := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", ArgoNamespace)
+	source.UID = "same-uid"
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	r, c := MockReconciler(source)
+	req := MockReconcileReq(source.Name, source.Namespace)
+
+	// Without GC, Kubernetes must not delete the ArgoSecret along with its source either.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Empty(t, argoSecret.OwnerReferences)
+
+	// ownerReferences set while GC was enabled are stripped.
+	argoSecret.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Secret", Name: source.Name, UID: source.UID}}
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Empty(t, argoSecret.OwnerReferences)
+}
+
+func TestReconcileGCRecheck(t *testing.T) {
+	oldConf, oldDelay := EnableGarbageCollection, GCRecheckDelay
+	defer func() { EnableGarbageCollection, GCRecheckDelay = oldConf, oldDelay }()
+	EnableGarbageCollection = true
+
+	GCRecheckDelay = time.Hour
+
+	source := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(source)
+	r.gcPending = &sync.Map{}
+	req := MockReconcileReq(source.Name, source.Namespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// A deleted source is requeued for a recheck instead of holding the worker.
+	assert.Nil(t, c.Delete(context.Background(), source))
+	res, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Greater(t, res.RequeueAfter, time.Duration(0))
+	assert.LessOrEqual(t, res.RequeueAfter, GCRecheckDelay)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// Reconciles before the delay elapsed wait for the rest of it only.
+	next, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, next.RequeueAfter, res.RequeueAfter)
+
+	// The source is recreated before the recheck, so it is synced as usual.
+	assert.Nil(t, c.Create(context.Background(), source.DeepCopy()))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+	_, pending := r.gcPending.Load(req.NamespacedName)
+	assert.False(t, pending)
+
+	// Once the source is still gone on recheck, its ArgoSecret is collected.
+	assert.Nil(t, c.Delete(context.Background(), source))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Greater(t, res.RequeueAfter, time.Duration(0))
+	GCRecheckDelay = time.Nanosecond
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+	_, pending = r.gcPending.Load(req.NamespacedName)
+	assert.False(t, pending)
+}
+
+func TestReconcileDeletionProtection(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	protectedSource := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", ArgoNamespace)
+	protectedSource.Annotations = map[string]string{protectedAnnotation: "true"}
+	otherSource := MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace)
+	otherSource.Labels[clusterv1.ClusterNameLabel] = "other"
+	r, c := MockReconciler(protectedSource, otherSource)
+	protectedKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	otherKey := types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}
+
+	for _, s := range []*corev1.Secret{protectedSource, otherSource} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+		assert.Nil(t, err)
+	}
+	// Both mocks share a server.
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning DuplicateServer")
+
+	// Protection is passed on from the source, and keeps Kubernetes from collecting the ArgoSecret too.
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), protectedKey, argoSecret))
+	assert.Equal(t, "true", argoSecret.Annotations[protectedAnnotation])
+	assert.Empty(t, argoSecret.OwnerReferences)
+
+	// Or set on the ArgoSecret itself.
+	assert.Nil(t, c.Get(context.Background(), otherKey, argoSecret))
+	argoSecret.Annotations[protectedAnnotation] = "true"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+
+	for _, s := range []*corev1.Secret{protectedSource, otherSource} {
+		assert.Nil(t, c.Delete(context.Background(), s))
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), protectedKey, &corev1.Secret{}))
+	assert.Nil(t, c.Get(context.Background(), otherKey, &corev1.Secret{}))
+
+	for range 2 {
+		select {
+		case e := <-recorder.Events:
+			assert.Contains(t, e, "Warning DeletionProtected")
+		default:
+			t.Fatal("expected a DeletionProtected event")
+		}
+	}
+}
+
+func TestDropOwnerReference(t *testing.T) {
+	t.Parallel()
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{{Name: "a", UID: "a"}, {Name: "b", UID: "b"}},
+	}}
+	assert.False(t, dropOwnerReference(s, "c"))
+	assert.Len(t, s.OwnerReferences, 2)
+	assert.True(t, dropOwnerReference(s, "a"))
+	assert.Equal(t, []metav1.OwnerReference{{Name: "b", UID: "b"}}, s.OwnerReferences)
+}
+
+func TestGcByOwnerReference(t *testing.T) {
+	t.Parallel()
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Secret", Name: "test-kubeconfig", UID: "uid"}
+	owned := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       ArgoNamespace,
+		OwnerReferences: []metav1.OwnerReference{owner},
+	}}
+	assert.True(t, gcByOwnerReference(owner, ArgoNamespace, owned))
+	assert.False(t, gcByOwnerReference(owner, ArgoNamespace, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ArgoNamespace}}))
+	assert.False(t, gcByOwnerReference(owner, TestNamespace, owned))
+
+	// Only the very same source owns it: same kind, apiVersion, name and UID.
+	for _, other := range []metav1.OwnerReference{
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "test-kubeconfig", UID: "uid"},
+		{APIVersion: "other/v1", Kind: "Secret", Name: "test-kubeconfig", UID: "uid"},
+		{APIVersion: "v1", Kind: "Secret", Name: "other-kubeconfig", UID: "uid"},
+		{APIVersion: "v1", Kind: "Secret", Name: "test-kubeconfig", UID: "other"},
+		{APIVersion: "v1", Kind: "Secret", Name: "test-kubeconfig"},
+	} {
+		assert.False(t, gcByOwnerReference(other, ArgoNamespace, owned), other)
+	}
+}
+
+func TestValidateSingleNamespace(t *testing.T) {
+	oldConf := SingleNamespace
+	defer func() { SingleNamespace = oldConf }()
+
+	SingleNamespace = ""
+	assert.Nil(t, ValidateSingleNamespace("a", "b"))
+
+	SingleNamespace = "a"
+	assert.Nil(t, ValidateSingleNamespace("a", "a"))
+	assert.ErrorIs(t, ValidateSingleNamespace("a", "b"), ErrCrossNamespace)
+	assert.ErrorIs(t, ValidateSingleNamespace("b", "a"), ErrCrossNamespace)
+}
+
+func TestValidateWatchNamespace(t *testing.T) {
+	oldConf := WatchNamespaces
+	defer func() { WatchNamespaces = oldConf }()
+	tests := []struct {
+		testName          string
+		testPatterns      []string
+		testNamespace     string
+		testExpectedValue bool
+	}{
+		{"test no patterns", nil, "any", true},
+		{"test literal match", []string{"capi-system"}, "capi-system", true},
+		{"test literal mismatch", []string{"capi-system"}, "capi-system-2", false},
+		{"test glob match", []string{"team-*"}, "team-a", true},
+		{"test glob mismatch", []string{"team-*"}, "teams", false},
+		{"test literal and glob, literal match", []string{"capi-system", "team-*"}, "capi-system", true},
+		{"test literal and glob, glob match", []string{"capi-system", "team-*"}, "team-b", true},
+		{"test literal and glob, no match", []string{"capi-system", "team-*"}, "default", false},
+		{"test character class", []string{"env-[ab]"}, "env-b", true},
+		{"test malformed pattern", []string{"team-["}, "team-[", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			WatchNamespaces = tt.testPatterns
+			assert.Equal(t, tt.testExpectedValue, ValidateWatchNamespace(tt.testNamespace))
+		})
+	}
+
+	assert.Nil(t, ValidateWatchNamespacePatterns([]string{"capi-system", "team-*", "env-[ab]"}))
+	assert.Error(t, ValidateWatchNamespacePatterns([]string{"team-["}))
+}
+
+func TestReconcileWatchNamespaces(t *testing.T) {
+	oldConf := WatchNamespaces
+	defer func() { WatchNamespaces = oldConf }()
+	WatchNamespaces = []string{"capi-system", "team-*"}
+
+	teamSource := MockCapiSecret(validMock, validType, validKey, "team-kubeconfig", "team-a")
+	teamSource.Labels[clusterv1.ClusterNameLabel] = "team"
+	otherSource := MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", "other")
+	otherSource.Labels[clusterv1.ClusterNameLabel] = "other"
+	r, c := MockReconciler(teamSource, otherSource)
+
+	for _, s := range []*corev1.Secret{teamSource, otherSource} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-team", Namespace: ArgoNamespace}, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{})))
+}
+
+func TestReconcileShadowNamespace(t *testing.T) {
+	oldConf, oldGC := ShadowNamespace, EnableGarbageCollection
+	defer func() { ShadowNamespace, EnableGarbageCollection = oldConf, oldGC }()
+	ShadowNamespace, EnableGarbageCollection = "argocd-shadow", true
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	shadowNN := types.NamespacedName{Name: "cluster-test", Namespace: ShadowNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret, shadowSecret := &corev1.Secret{}, &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Nil(t, c.Get(context.Background(), shadowNN, shadowSecret))
+	assert.NotContains(t, argoSecret.Labels, shadowKey)
+	assert.Equal(t, "true", shadowSecret.Labels[shadowKey])
+	assert.Equal(t, argoSecret.Data, shadowSecret.Data)
+
+	// Updates are mirrored too.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), shadowNN, shadowSecret))
+	assert.Contains(t, string(shadowSecret.Data["config"]), `"bearerToken":"rotated"`)
+
+	// Garbage collection counts the primary only, but removes both.
+	deleted := MockCounterValue(secretsDeletedTotal)
+	assert.Nil(t, c.Delete(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+	assert.NotNil(t, c.Get(context.Background(), shadowNN, &corev1.Secret{}))
+	assert.Equal(t, deleted+1, MockCounterValue(secretsDeletedTotal))
+}
+
+func TestReconcileCABundle(t *testing.T) {
+	oldConf, oldKey, oldForce := CABundleConfigMap, CABundleKey, ForceCABundle
+	defer func() { CABundleConfigMap, CABundleKey, ForceCABundle = oldConf, oldKey, oldForce }()
+	CABundleConfigMap = types.NamespacedName{Name: "trust-bundle", Namespace: ArgoNamespace}
+	CABundleKey = "ca.crt"
+	ForceCABundle = true
+
+	bundle := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: CABundleConfigMap.Name, Namespace: CABundleConfigMap.Namespace},
+		Data:       map[string]string{"ca.crt": "bundle"},
+	}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), bundle)
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, argoSecret))
+	var config ArgoConfig
+	assert.Nil(t, json.Unmarshal(argoSecret.Data["config"], &config))
+	assert.Equal(t, "YnVuZGxl", *config.TLSClientConfig.CaData)
+
+	// A missing bundle fails the reconcile.
+	r, _ = MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.NotNil(t, err)
+}
+
+func TestReconcileClusterSelector(t *testing.T) {
+	oldConf, oldGC := ClusterSelector, EnableGarbageCollection
+	defer func() { ClusterSelector, EnableGarbageCollection = oldConf, oldGC }()
+	ClusterSelector, _ = labels.Parse("env in (prod,staging)")
+	EnableGarbageCollection = true
+
+	cluster := MockCluster("test", TestNamespace, map[string]string{"env": "prod"}, nil)
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// A cluster no longer matching is unregistered.
+	cluster.Labels["env"] = "dev"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestReconcileClusterSelectorFetchFailure(t *testing.T) {
+	oldConf, oldGC := ClusterSelector, EnableGarbageCollection
+	defer func() { ClusterSelector, EnableGarbageCollection = oldConf, oldGC }()
+	ClusterSelector, _ = labels.Parse("env in (prod,staging)")
+	EnableGarbageCollection = true
+
+	cluster := MockCluster("test", TestNamespace, map[string]string{"env": "prod"}, nil)
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// The labels of the Cluster are unknown, so the ArgoSecret is kept and the fetch retried.
+	c.OnGet = func(key client.ObjectKey, obj client.Object) error {
+		if _, ok := obj.(*clusterv1.Cluster); ok {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+	_, err = r.Reconcile(context.Background(), req)
+	assert.NotNil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// Neither is it collected while the Cluster is missing.
+	c.OnGet = nil
+	assert.Nil(t, c.Delete(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestReconcileClusterNameAllowlist(t *testing.T) {
+	oldConf, oldGC := ClusterNameAllowlist, EnableGarbageCollection
+	defer func() { ClusterNameAllowlist, EnableGarbageCollection = oldConf, oldGC }()
+	ClusterNameAllowlist = []string{TestNamespace + "/test"}
+	EnableGarbageCollection = true
+
+	staged := MockCapiSecret(validMock, validType, validKey, "staged-kubeconfig", TestNamespace)
+	staged.Labels[clusterv1.ClusterNameLabel] = "staged"
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), staged)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	stagedKey := types.NamespacedName{Name: "cluster-staged", Namespace: ArgoNamespace}
+
+	// Only the allowlisted cluster is registered.
+	for _, name := range []string{"test-kubeconfig", "staged-kubeconfig"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name, TestNamespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), stagedKey, &corev1.Secret{})))
+
+	// The allowlist grows to the next stage, and shrinks back.
+	ClusterNameAllowlist = []string{"test", "staged"}
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("staged-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), stagedKey, &corev1.Secret{}))
+
+	// Not while the Cluster can not be fetched though.
+	ClusterNameAllowlist = []string{"test"}
+	c.OnGet = func(key client.ObjectKey, obj client.Object) error {
+		if _, ok := obj.(*clusterv1.Cluster); ok {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("staged-kubeconfig", TestNamespace))
+	assert.NotNil(t, err)
+	assert.Nil(t, c.Get(context.Background(), stagedKey, &corev1.Secret{}))
+
+	c.OnGet = nil
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("staged-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), stagedKey, &corev1.Secret{})))
+	assert.Contains(t, <-recorder.Events, "NotAllowlisted")
+
+	// Unregistered clusters are not announced again.
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("staged-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestReconcileSharedKubeConfig(t *testing.T) {
+	// The imported-kubeconfig secret is named after the imported Cluster, but labeled for the test one.
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "imported-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret,
+		MockCluster("test", TestNamespace, map[string]string{"env": "labeled", clusterTakeAlongKey + "env": ""}, nil),
+		MockCluster("imported", TestNamespace, map[string]string{"env": "named", clusterTakeAlongKey + "env": ""}, nil),
+	)
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	// Every reconcile deterministically uses the labeled Cluster, and warns about the other.
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(capiSecret.Name, TestNamespace))
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, "Warning AmbiguousCluster CapiSecret is referenced by Clusters test")
+		argoSecret := &corev1.Secret{}
+		assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-imported", Namespace: ArgoNamespace}, argoSecret))
+		assert.Equal(t, "labeled", argoSecret.Labels["env"])
+	}
+
+	// Without the named Cluster, nothing is ambiguous.
+	assert.Nil(t, c.Delete(context.Background(), MockCluster("imported", TestNamespace, nil, nil)))
+	_, err := r.Reconcile(context.Background(), MockReconcileReq(capiSecret.Name, TestNamespace))
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestReconcileAllowedArgoNamespaces(t *testing.T) {
+	oldConf := AllowedArgoNamespaces
+	defer func() { AllowedArgoNamespaces = oldConf }()
+	AllowedArgoNamespaces = []string{ArgoNamespace}
+
+	routed := MockCapiSecret(validMock, validType, validKey, "routed-kubeconfig", TestNamespace)
+	routed.Labels[clusterv1.ClusterNameLabel] = "routed"
+	r, c := MockReconciler(
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), routed,
+		MockCluster("routed", TestNamespace, nil, map[string]string{clusterArgoInstanceKey: "argocd-typo"}),
+	)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	routedKey := types.NamespacedName{Name: "cluster-routed", Namespace: "argocd-typo"}
+
+	// Clusters resolving to an allowed namespace are registered, the others are rejected.
+	for _, name := range []string{"test-kubeconfig", "routed-kubeconfig"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name, TestNamespace))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), routedKey, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning DisallowedArgoNamespace ArgoCD namespace argocd-typo is not allowed")
+
+	// Allowing the namespace registers the cluster there.
+	AllowedArgoNamespaces = []string{ArgoNamespace, "argocd-typo"}
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("routed-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), routedKey, &corev1.Secret{}))
+}
+
+func TestReconcileArgoInstanceWithoutAllowlist(t *testing.T) {
+	oldConf := AllowedArgoNamespaces
+	defer func() { AllowedArgoNamespaces = oldConf }()
+	AllowedArgoNamespaces = nil
+
+	r, c := MockReconciler(
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace),
+		MockCluster("test", TestNamespace, nil, map[string]string{clusterArgoInstanceKey: "kube-system"}),
+	)
+
+	// Without an allowlist, routing is denied and only ArgoNamespace is written to.
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: "kube-system"}, &corev1.Secret{})))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning DisallowedArgoNamespace ArgoCD namespace kube-system is not allowed, allowed namespaces are: "+ArgoNamespace)
+}
+
+func TestReconcileServerTemplate(t *testing.T) {
+	oldConf := AllowServerTemplates
+	defer func() { AllowServerTemplates = oldConf }()
+
+	r, c := MockReconciler(
+		MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace),
+		MockCluster("test", TestNamespace, nil, map[string]string{clusterServerTemplateKey: "https://{{.Name}}.api.example.com:6443"}),
+	)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// Server templates are skipped unless explicitly allowed.
+	AllowServerTemplates = false
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning DisallowedServerTemplate")
+
+	AllowServerTemplates = true
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "https://test.api.example.com:6443", string(argoSecret.Data["server"]))
+}
+
+func TestValidateClusterAllowlist(t *testing.T) {
+	oldConf := ClusterNameAllowlist
+	defer func() { ClusterNameAllowlist = oldConf }()
+	tests := []struct {
+		testName          string
+		testAllowlist     []string
+		testNamespace     string
+		testCluster       string
+		testExpectedValue bool
+	}{
+		{"test no allowlist", nil, "ns", "test", true},
+		{"test allowlisted name", []string{"other", "test"}, "ns", "test", true},
+		{"test allowlisted namespaced name", []string{"ns/test"}, "ns", "test", true},
+		{"test namespaced name in other namespace", []string{"other/test"}, "ns", "test", false},
+		{"test name not allowlisted", []string{"other"}, "ns", "test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ClusterNameAllowlist = tt.testAllowlist
+			assert.Equal(t, tt.testExpectedValue, validateClusterAllowlist(tt.testNamespace, tt.testCluster))
+		})
+	}
+}
+
+func TestValidateClusterSelector(t *testing.T) {
+	oldConf := ClusterSelector
+	defer func() { ClusterSelector = oldConf }()
+	tests := []struct {
+		testName          string
+		testSelector      string
+		testMock          *clusterv1.Cluster
+		testExpectedValue bool
+	}{
+		{"test no selector", "", MockCluster("test", "test", nil, nil), true},
+		{"test matching cluster", "env=prod", MockCluster("test", "test", map[string]string{"env": "prod"}, nil), true},
+		{"test non-matching cluster", "env=prod", MockCluster("test", "test", map[string]string{"env": "dev"}, nil), false},
+		{"test cluster without labels", "env", MockCluster("test", "test", nil, nil), false},
+		{"test cluster with excluded label", "!capi.version", MockCluster("test", "test", map[string]string{"capi.version": "v1"}, nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ClusterSelector = nil
+			if tt.testSelector != "" {
+				ClusterSelector, _ = labels.Parse(tt.testSelector)
+			}
+			assert.Equal(t, tt.testExpectedValue, validateClusterSelector(tt.testMock))
+		})
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	t.Parallel()
+	secret := func(config string, labels map[string]string, annotations map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations},
+			Data: map[string][]byte{
+				"name":   []byte("test"),
+				"server": []byte("https://test:6443"),
+				"config": []byte(config),
+			},
+		}
+	}
+	base := secret(`{"bearerToken":"secret-a"}`, map[string]string{"env": "prod"}, nil)
+	tests := []struct {
+		testName           string
+		testMock           *corev1.Secret
+		testExpectedValues []string
+	}{
+		{"test no changes", secret(`{"bearerToken":"secret-a"}`, map[string]string{"env": "prod"}, nil), nil},
+		{"test config change", secret(`{"bearerToken":"secret-b"}`, map[string]string{"env": "prod"}, nil), []string{"data.config"}},
+		{"test label value change", secret(`{"bearerToken":"secret-a"}`, map[string]string{"env": "dev"}, nil), []string{"label.env"}},
+		{"test label and annotation changes", secret(`{"bearerToken":"secret-b"}`, map[string]string{"team": "a"}, map[string]string{"note": "a"}),
+			[]string{"annotation.note", "data.config", "label.env", "label.team"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			diff := diffSummary(base, tt.testMock)
+			assert.Equal(t, tt.testExpectedValues, diff)
+			assert.NotContains(t, fmt.Sprint(diff), "secret-")
+		})
+	}
+}
+
+func TestReconcileHeaders(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterHeaderPrefix + "X-Tenant": "a"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// Changing a header annotation is detected as drift.
+	cluster.Annotations[clusterHeaderPrefix+"X-Tenant"] = "b"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	var config ArgoConfig
+	assert.Nil(t, json.Unmarshal(argoSecret.Data["config"], &config))
+	assert.Equal(t, map[string]string{"X-Tenant": "b"}, config.Headers)
+}
+
+func TestReconcileInCluster(t *testing.T) {
+	oldConf, oldAllow := ConfigSource, AllowSelfRegistration
+	defer func() { ConfigSource, AllowSelfRegistration = oldConf, oldAllow }()
+	// Out-of-band credentials are not needed, so the missing CredentialsSecret is not an error.
+	ConfigSource = ConfigSourceServerCAOnly
+
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterInClusterKey: "true"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// The annotation alone does not bypass the self-registration guard.
+	AllowSelfRegistration = false
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	assert.Contains(t, <-recorder.Events, "Warning SelfRegistration")
+
+	AllowSelfRegistration = true
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, InClusterServer, string(argoSecret.Data["server"]))
+	assert.JSONEq(t, `{"tlsClientConfig":{}}`, string(argoSecret.Data["config"]))
+}
+
+func TestReconcileTenant(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterTenantKey: "team-a"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "team-a", string(argoSecret.Data["project"]))
+	assert.Equal(t, "team-a", string(argoSecret.Data["namespaces"]))
+	assert.Equal(t, "false", string(argoSecret.Data["clusterResources"]))
+
+	// Dropping the annotation unbinds the existing ArgoSecret.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(cluster), cluster))
+	delete(cluster.Annotations, clusterTenantKey)
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Data, "project")
+	assert.NotContains(t, argoSecret.Data, "namespaces")
+	assert.NotContains(t, argoSecret.Data, "clusterResources")
+}
+
+func TestReconcileWaitForReadyCondition(t *testing.T) {
+	oldConf := WaitForReadyCondition
+	defer func() { WaitForReadyCondition = oldConf }()
+	WaitForReadyCondition = true
+
+	cluster := MockCluster("test", TestNamespace, nil, nil)
+	cluster.Status.Conditions = clusterv1.Conditions{{Type: clusterv1.ReadyCondition, Status: corev1.ConditionFalse}}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	res, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, ReadyConditionRequeueAfter, res.RequeueAfter)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// The cluster is registered once Ready.
+	cluster.Status.Conditions[0].Status = corev1.ConditionTrue
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// Kubeconfigs without a Cluster are registered right away.
+	r, c = MockReconciler(MockCapiSecret(validMock, validType, validKey, "other-kubeconfig", TestNamespace))
+	res, err = r.Reconcile(context.Background(), MockReconcileReq("other-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-other", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcilePaused(t *testing.T) {
+	oldConf := OperatorNamespace
+	defer func() { OperatorNamespace = oldConf }()
+	OperatorNamespace = "caco-system"
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	operatorNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        OperatorNamespace,
+		Annotations: map[string]string{pausedAnnotation: "true"},
+	}}
+	r, c := MockReconciler(capiSecret, operatorNamespace)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	res, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, PausedRequeueAfter, res.RequeueAfter)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	assert.NotContains(t, capiSecret.Annotations, syncStatusAnnotation)
+
+	// Unpausing resumes writes.
+	delete(operatorNamespace.Annotations, pausedAnnotation)
+	assert.Nil(t, c.Update(context.Background(), operatorNamespace))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+
+	// So does a missing namespace.
+	assert.Nil(t, c.Delete(context.Background(), operatorNamespace))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+}
+
+// mockHangingNamespaceClient never answers Namespace reads, as a cache whose Namespace
+// informer never syncs.
+type mockHangingNamespaceClient struct{ client.Client }
+
+func (c mockHangingNamespaceClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*corev1.Namespace); !ok {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReconcilePausedCheckTimeout(t *testing.T) {
+	oldConf, oldTimeout := OperatorNamespace, PausedCheckTimeout
+	defer func() { OperatorNamespace, PausedCheckTimeout = oldConf, oldTimeout }()
+	OperatorNamespace = "caco-system"
+	PausedCheckTimeout = 10 * time.Millisecond
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	r.Client = mockHangingNamespaceClient{Client: c}
+	res, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+// mockMultiContextKubeConfig holds three contexts, the last two sharing a cluster.
+const mockMultiContextKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: a
+  cluster:
+    server: https://a.domain.com:6443
+- name: b
+  cluster:
+    server: https://b.domain.com:6443
+users:
+- name: admin
+  user:
+    token: admin
+- name: viewer
+  user:
+    token: viewer
+contexts:
+- name: admin@a
+  context:
+    cluster: a
+    user: admin
+- name: admin@b
+  context:
+    cluster: b
+    user: admin
+- name: viewer@b
+  context:
+    cluster: b
+    user: viewer
+`
+
+func TestReconcileRegisterAllContexts(t *testing.T) {
+	oldConf, oldGC := RegisterAllContexts, EnableGarbageCollection
+	defer func() { RegisterAllContexts, EnableGarbageCollection = oldConf, oldGC }()
+	RegisterAllContexts, EnableGarbageCollection = true, true
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	capiSecret.Data["value"] = []byte(mockMultiContextKubeConfig)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	expected := map[string]string{
+		"cluster-test-admin-a":  "https://a.domain.com:6443",
+		"cluster-test-admin-b":  "https://b.domain.com:6443",
+		"cluster-test-viewer-b": "https://b.domain.com:6443",
+	}
+	for name, server := range expected {
+		argoSecret := &corev1.Secret{}
+		assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ArgoNamespace}, argoSecret))
+		assert.Equal(t, server, string(argoSecret.Data["server"]))
+	}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test-viewer-b", Namespace: ArgoNamespace}, capiSecret))
+	assert.Contains(t, string(capiSecret.Data["config"]), `"bearerToken":"viewer"`)
+
+	// Reconciling again keeps all of them.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	for name := range expected {
+		assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ArgoNamespace}, &corev1.Secret{}))
+	}
+
+	// All of them are collected along with their source.
+	assert.Nil(t, c.Delete(context.Background(), MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	for name := range expected {
+		assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ArgoNamespace}, &corev1.Secret{})))
+	}
+}
+
+// slowClient blocks Get calls until ctx is done.
+type slowClient struct {
+	*MockClient
+}
+
+func (c *slowClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	select {
+	case <-time.After(time.Minute):
+		return c.MockClient.Get(ctx, key, obj, opts...)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// coldCacheClient fails the first Get calls of Clusters, like a cache not started yet, or
+// misses them when notFound is set.
+type coldCacheClient struct {
+	*MockClient
+	misses   int
+	notFound bool
+}
+
+func (c *coldCacheClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*clusterv1.Cluster); ok && c.misses > 0 {
+		c.misses--
+		if c.notFound {
+			return mockNotFound("Cluster", key.Name)
+		}
+		return &cache.ErrCacheNotStarted{}
+	}
+	return c.MockClient.Get(ctx, key, obj, opts...)
+}
+
+func TestReconcileClusterFetchRetry(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, map[string]string{"env": "prod", clusterTakeAlongKey + "env": ""}, nil)
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	r.Client = &coldCacheClient{MockClient: c, misses: 1}
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// The Cluster shows up on the second attempt, so its labels are taken along.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+
+	// Failures outlasting the retries fail the reconcile, without touching the ArgoSecret.
+	r.Client = &coldCacheClient{MockClient: c, misses: clusterFetchBackoff.Steps}
+	_, err = r.Reconcile(context.Background(), req)
+	assert.NotNil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+
+	// A missing Cluster is not retried.
+	coldCache := &coldCacheClient{MockClient: c, misses: 2, notFound: true}
+	r.Client = coldCache
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, coldCache.misses)
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	oldConf := ReconcileTimeout
+	defer func() { ReconcileTimeout = oldConf }()
+	ReconcileTimeout = 50 * time.Millisecond
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	r.Client = &slowClient{c}
+
+	start := time.Now()
+	result, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, result.Requeue)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestReconcileArgoInstance(t *testing.T) {
+	oldConf, oldAllowed := EnableGarbageCollection, AllowedArgoNamespaces
+	defer func() { EnableGarbageCollection, AllowedArgoNamespaces = oldConf, oldAllowed }()
+	EnableGarbageCollection = true
+	AllowedArgoNamespaces = []string{ArgoNamespace, "argocd-a", "argocd-b"}
+
+	clusterA := MockCluster("a", TestNamespace, nil, map[string]string{clusterArgoInstanceKey: "argocd-a"})
+	clusterB := MockCluster("b", TestNamespace, nil, map[string]string{clusterArgoInstanceKey: "argocd-b"})
+	capiSecretA := MockCapiSecret(validMock, validType, validKey, "a-kubeconfig", TestNamespace)
+	capiSecretA.Labels[clusterv1.ClusterNameLabel] = "a"
+	capiSecretB := MockCapiSecret(validMock, validType, validKey, "b-kubeconfig", TestNamespace)
+	capiSecretB.Labels[clusterv1.ClusterNameLabel] = "b"
+	r, c := MockReconciler(capiSecretA, capiSecretB, clusterA, clusterB)
+	for _, name := range []string{"a-kubeconfig", "b-kubeconfig"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name, TestNamespace))
+		assert.Nil(t, err)
+	}
+
+	// Each cluster is routed to its own ArgoCD instance.
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-a", Namespace: "argocd-a"}, &corev1.Secret{}))
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-b", Namespace: "argocd-b"}, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-a", Namespace: ArgoNamespace}, &corev1.Secret{})))
+
+	// Rerouting a cluster moves its ArgoSecret.
+	clusterA.Annotations[clusterArgoInstanceKey] = "argocd-b"
+	assert.Nil(t, c.Update(context.Background(), clusterA))
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("a-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-a", Namespace: "argocd-b"}, &corev1.Secret{}))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-a", Namespace: "argocd-a"}, &corev1.Secret{})))
+
+	// And deleted sources are collected from their chosen target.
+	assert.Nil(t, c.Delete(context.Background(), capiSecretB))
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("b-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Name: "cluster-b", Namespace: "argocd-b"}, &corev1.Secret{})))
+}
+
+func TestReconcileConfigOverrides(t *testing.T) {
+	cluster := MockCluster("test", TestNamespace, nil, map[string]string{clusterConfigOverridesKey: `{"proxyUrl":"http://a:3128"}`})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), `"proxyUrl":"http://a:3128"`)
+
+	// Changing the overrides is detected as drift.
+	cluster.Annotations[clusterConfigOverridesKey] = `{"proxyUrl":"http://b:3128"}`
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), `"proxyUrl":"http://b:3128"`)
+}
+
+func TestReconcileLogVerbosity(t *testing.T) {
+	tests := []struct {
+		testName             string
+		testVerbosity        int
+		testExpectedValues   []string
+		testUnexpectedValues []string
+	}{
+		{"test default verbosity", 0,
+			[]string{"Fetched CapiSecret", "Created new ArgoSecret"},
+			[]string{"Checking if ArgoSecret is out-of-sync with", "Reconciling CapiSecret"}},
+		{"test verbosity 1", 1,
+			[]string{"Fetched CapiSecret", "Checking if ArgoSecret is out-of-sync with"},
+			[]string{"Reconciling CapiSecret"}},
+		{"test verbosity 2", 2,
+			[]string{"Fetched CapiSecret", "Checking if ArgoSecret is out-of-sync with", "Reconciling CapiSecret", "Reconciled CapiSecret"},
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r, _ := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+			var sink *MockLogSink
+			r.Log, sink = NewMockLogger(tt.testVerbosity)
+			req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+			// Reconcile twice, to go through both the create and the update path.
+			for range 2 {
+				_, err := r.Reconcile(context.Background(), req)
+				assert.Nil(t, err)
+			}
+			for _, m := range tt.testExpectedValues {
+				assert.Contains(t, sink.Messages(), m)
+			}
+			for _, m := range tt.testUnexpectedValues {
+				assert.NotContains(t, sink.Messages(), m)
+			}
+		})
+	}
+}
+
+func TestReconcileUpdateConflict(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// The first update of the ArgoSecret conflicts with a concurrent writer.
+	conflicts := 0
+	c.OnUpdate = func(obj client.Object) error {
+		if obj.GetNamespace() == ArgoNamespace && conflicts == 0 {
+			conflicts++
+			return apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, obj.GetName(), fmt.Errorf("concurrent update"))
+		}
+		return nil
+	}
+	rotations := MockCounterValue(tokenRotationsTotal)
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, conflicts)
+	assert.Equal(t, rotations+1, MockCounterValue(tokenRotationsTotal))
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), `"bearerToken":"rotated"`)
+}
+
+func TestReconcileDriftCorrectionPatch(t *testing.T) {
+	oldConf := DriftCorrectionMode
+	defer func() { DriftCorrectionMode = oldConf }()
+	DriftCorrectionMode = DriftCorrectionModePatch
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	c.OnUpdate = func(obj client.Object) error {
+		if obj.GetNamespace() == ArgoNamespace {
+			t.Errorf("unexpected update of ArgoSecret %s", obj.GetName())
+		}
+		return nil
+	}
+	var payloads []map[string]map[string]interface{}
+	c.OnPatch = func(obj client.Object, patch client.Patch) error {
+		data, err := patch.Data(obj)
+		if err != nil {
+			return err
+		}
+		var payload map[string]map[string]interface{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		payloads = append(payloads, payload)
+		return nil
+	}
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// Only the changed fields are sent.
+	if assert.Len(t, payloads, 1) {
+		assert.ElementsMatch(t, []string{"data", "metadata"}, slices.Collect(maps.Keys(payloads[0])))
+		assert.ElementsMatch(t, []string{ArgoConfigKey}, slices.Collect(maps.Keys(payloads[0]["data"])))
+		assert.ElementsMatch(t, []string{"annotations"}, slices.Collect(maps.Keys(payloads[0]["metadata"])))
+	}
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, argoSecret))
+	assert.Contains(t, string(argoSecret.Data[ArgoConfigKey]), `"bearerToken":"rotated"`)
+
+	// Once converged, nothing is patched.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Len(t, payloads, 1)
+}
+
+func TestReconcileFieldManager(t *testing.T) {
+	oldConf := FieldManager
+	defer func() { FieldManager = oldConf }()
+	FieldManager = "caco-test"
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	r.Client = WithFieldManager(c)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	argoSecret := &corev1.Secret{}
+
+	// The ArgoSecret is created as FieldManager.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "caco-test", c.FieldManager(argoSecret))
+
+	// And updated as FieldManager, after a write of another manager.
+	argoSecret.Data["name"] = []byte("tampered")
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	assert.Equal(t, "", c.FieldManager(argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "caco-test", c.FieldManager(argoSecret))
+}
+
+func TestReconcileRotationAnnotation(t *testing.T) {
+	oldConf := RotationAnnotation
+	defer func() { RotationAnnotation = oldConf }()
+	RotationAnnotation = "cluster.x-k8s.io/rotated-at"
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	capiSecret.Annotations = map[string]string{RotationAnnotation: "2024-01-01T00:00:00Z"}
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	argoSecret := &corev1.Secret{}
+
+	// Nothing changed, nothing is written.
+	AssertReconcileIdempotent(t, c, r, req)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "2024-01-01T00:00:00Z", argoSecret.Annotations[rotationAnnotation])
+	config, version := argoSecret.Data["config"], argoSecret.ResourceVersion
+
+	// Only the rotation annotation changed, the config is rewritten all the same.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Annotations[RotationAnnotation] = "2024-02-01T00:00:00Z"
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotEqual(t, version, argoSecret.ResourceVersion)
+	assert.Equal(t, "2024-02-01T00:00:00Z", argoSecret.Annotations[rotationAnnotation])
+	assert.Equal(t, config, argoSecret.Data["config"])
+}
+
+func TestReconcileArgoDataKeys(t *testing.T) {
+	oldName, oldServer, oldConfig := ArgoNameKey, ArgoServerKey, ArgoConfigKey
+	defer func() { ArgoNameKey, ArgoServerKey, ArgoConfigKey = oldName, oldServer, oldConfig }()
+	ArgoNameKey, ArgoServerKey, ArgoConfigKey = "clusterName", "clusterServer", "clusterConfig"
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "kube-cluster-test", string(argoSecret.Data["clusterName"]))
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["clusterServer"]))
+	assert.Contains(t, string(argoSecret.Data["clusterConfig"]), `"bearerToken":"test"`)
+	for _, key := range []string{"name", "server", "config"} {
+		assert.NotContains(t, argoSecret.Data, key)
+	}
+
+	// Drift is detected under the custom keys.
+	argoSecret.Data["clusterServer"] = []byte("https://tampered:6443")
+	argoSecret.Data["clusterConfig"] = []byte(`{}`)
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["clusterServer"]))
+	assert.Contains(t, string(argoSecret.Data["clusterConfig"]), `"bearerToken":"test"`)
+}
+
+func TestReconcileUserLabels(t *testing.T) {
+	oldConf := AllowRecreate
+	defer func() { AllowRecreate = oldConf }()
+	AllowRecreate = true
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	rotate := func(from string, to string) {
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+		capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: "+from), []byte("token: "+to), 1)
+		assert.Nil(t, c.Update(context.Background(), capiSecret))
+		_, err := r.Reconcile(context.Background(), req)
+		assert.Nil(t, err)
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	argoSecret.Labels["team"] = "platform"
+	argoSecret.Annotations = map[string]string{"owner": "admin"}
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+
+	// User labels survive in-place updates.
+	rotate("test", "rotated")
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+	assert.Equal(t, "admin", argoSecret.Annotations["owner"])
+
+	// And recreations.
+	c.OnUpdate = func(obj client.Object) error {
+		if obj.GetNamespace() == ArgoNamespace {
+			return MockImmutableError(obj.GetName())
+		}
+		return nil
+	}
+	rotate("rotated", "recreated")
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), `"bearerToken":"recreated"`)
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+	assert.Equal(t, "admin", argoSecret.Annotations["owner"])
+}
+
+func TestReconcileApplicationSetLabels(t *testing.T) {
+	oldConf := ApplicationSetLabels
+	defer func() { ApplicationSetLabels = oldConf }()
+	ApplicationSetLabels = map[string]string{"clusters": "capi", "env": "prod"}
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "capi", argoSecret.Labels["clusters"])
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+	assert.Equal(t, "cluster", argoSecret.Labels["argocd.argoproj.io/secret-type"])
+
+	// Drifted labels are corrected.
+	argoSecret.Labels["clusters"] = "manual"
+	delete(argoSecret.Labels, "env")
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "capi", argoSecret.Labels["clusters"])
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+
+	// Labels dropped from the configuration are removed, others are kept.
+	argoSecret.Labels["team"] = "platform"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	ApplicationSetLabels = map[string]string{"clusters": "capi"}
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "capi", argoSecret.Labels["clusters"])
+	assert.NotContains(t, argoSecret.Labels, "env")
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+	assert.Equal(t, "clusters", argoSecret.Annotations[applicationSetLabelsAnnotation])
+	AssertReconcileIdempotent(t, c, r, req)
+
+	ApplicationSetLabels = nil
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Labels, "clusters")
+	assert.NotContains(t, argoSecret.Annotations, applicationSetLabelsAnnotation)
+}
+
+func TestReconcileTemplatedLabels(t *testing.T) {
+	oldConf := TemplatedLabels
+	defer func() { TemplatedLabels = oldConf }()
+	templates, err := ParseTemplatedLabels("team={{.Labels.team}}")
+	assert.Nil(t, err)
+	TemplatedLabels = templates
+
+	cluster := MockCluster("test", TestNamespace, map[string]string{"team": "infra"}, nil)
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "infra", argoSecret.Labels["team"])
+
+	// Changes of the source field are rendered again.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(cluster), cluster))
+	cluster.Labels["team"] = "platform"
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+
+	// Drifted labels are corrected.
+	argoSecret.Labels["team"] = "manual"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+	assert.Equal(t, "team", argoSecret.Annotations[templatedLabelsAnnotation])
+
+	// Labels dropped from the configuration are removed, others are kept.
+	argoSecret.Labels["env"] = "prod"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	TemplatedLabels = nil
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, argoSecret.Labels, "team")
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+	assert.NotContains(t, argoSecret.Annotations, templatedLabelsAnnotation)
+	AssertReconcileIdempotent(t, c, r, req)
+}
+
+func TestReconcileTakeoverSecretType(t *testing.T) {
+	oldConf := TakeoverSecretType
+	defer func() { TakeoverSecretType = oldConf }()
+
+	tests := []struct {
+		testName             string
+		testSecretType       string
+		testTakeover         bool
+		testExpectedTakeover bool
+	}{
+		{"test cluster secret", "cluster", true, true},
+		{"test takeover disabled", "cluster", false, false},
+		{"test repository secret", "repository", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			TakeoverSecretType = tt.testTakeover
+			foreign := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-test",
+					Namespace: ArgoNamespace,
+					Labels:    map[string]string{"argocd.argoproj.io/secret-type": tt.testSecretType, "team": "infra"},
+				},
+				Data: map[string][]byte{"name": []byte("legacy"), "server": []byte("https://legacy.domain.com")},
+			}
+			r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), foreign)
+			req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+			AssertReconcileIdempotent(t, c, r, req)
+			argoSecret := &corev1.Secret{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(foreign), argoSecret))
+			recorder := r.Recorder.(*record.FakeRecorder)
+			if !tt.testExpectedTakeover {
+				assert.Equal(t, foreign.Labels, argoSecret.Labels)
+				assert.Equal(t, foreign.Data, argoSecret.Data)
+				assert.Len(t, recorder.Events, 0)
+				return
+			}
+			assert.Equal(t, "true", argoSecret.Labels["capi-to-argocd/owned"])
+			assert.Equal(t, "test-kubeconfig", argoSecret.Labels["capi-to-argocd/cluster-secret-name"])
+			assert.Equal(t, TestNamespace, argoSecret.Labels["capi-to-argocd/cluster-namespace"])
+			assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["server"]))
+			// Labels set by the other tool are kept.
+			assert.Equal(t, "infra", argoSecret.Labels["team"])
+			assert.Equal(t, "Normal TakenOver Took over ArgoSecret "+ArgoNamespace+"/cluster-test created by another tool", <-recorder.Events)
+		})
+	}
+}
+
+func TestReconcileProject(t *testing.T) {
+	t.Parallel()
+	cluster := MockCluster("test", TestNamespace, map[string]string{clusterProjectKey: "from-label"}, map[string]string{clusterProjectKey: "team-a"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cluster)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	argoSecret := &corev1.Secret{}
+
+	// The annotation takes precedence over the label.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "team-a", string(argoSecret.Data["project"]))
+	assert.NotContains(t, argoSecret.Data, "namespaces")
+
+	setAnnotation := func(value string) {
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(cluster), cluster))
+		if value == "" {
+			delete(cluster.Annotations, clusterProjectKey)
+		} else {
+			cluster.Annotations[clusterProjectKey] = value
+		}
+		assert.Nil(t, c.Update(context.Background(), cluster))
+		_, err := r.Reconcile(context.Background(), req)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	}
+
+	// Project changes are synced.
+	setAnnotation("team-b")
+	assert.Equal(t, "team-b", string(argoSecret.Data["project"]))
+
+	// The label applies without the annotation.
+	setAnnotation("")
+	assert.Equal(t, "from-label", string(argoSecret.Data["project"]))
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	writes := 0
+	c.OnCreate = func(client.Object) error { writes++; return nil }
+	c.OnUpdate = func(client.Object) error { writes++; return nil }
+
+	// ArgoSecrets are not created.
+	r.DryRun = true
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, capiSecret))
+	assert.NotContains(t, capiSecret.Annotations, syncStatusAnnotation)
+	assert.Equal(t, 0, writes)
+
+	r.DryRun = false
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+
+	// Out-of-sync ArgoSecrets are not updated.
+	r.DryRun = true
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	writes = 0
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	drifted := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, drifted))
+	assert.Equal(t, argoSecret.Data, drifted.Data)
+	assert.Equal(t, 0, writes)
+
+	// Orphaned ArgoSecrets are not garbage collected.
+	assert.Nil(t, c.Delete(context.Background(), &corev1.Secret{ObjectMeta: capiSecret.ObjectMeta}))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestDryRunWriters(t *testing.T) {
+	oldConf := CurrentConfig()
+	defer ApplyConfig(oldConf)
+
+	kubeConfig := MockCapiSecret(validMock, validType, validKey, "imported", TestNamespace)
+	kubeConfig.Type = corev1.SecretTypeOpaque
+	kubeConfig.Data = map[string][]byte{"kubeconfig": kubeConfig.Data["value"]}
+	reg := MockClusterRegistration("imported-cluster", TestNamespace, "imported")
+	legacySecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster-legacy",
+		Namespace: ArgoNamespace,
+		Labels:    map[string]string{"capi2argo/owned": "true"},
+	}}
+	cm := MockConfigMap(map[string]string{"ARGOCD_NAMESPACE": "argocd-new"})
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), kubeConfig, reg, legacySecret, cm)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	cr := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+	regReq := MockReconcileReq(reg.Name, reg.Namespace)
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	_, err = cr.Reconcile(context.Background(), regReq)
+	assert.Nil(t, err)
+
+	// Only writes made while frozen are counted, the others set the scene.
+	frozen, writes := false, 0
+	count := func(client.Object) error {
+		if frozen {
+			writes++
+		}
+		return nil
+	}
+	c.OnCreate, c.OnUpdate, c.OnDelete = count, count, count
+	c.OnPatch = func(obj client.Object, _ client.Patch) error { return count(obj) }
+	r.DryRun, cr.DryRun = true, true
+
+	// Updates of the ArgoSecrets of ClusterRegistrations, and their deletion.
+	reg.Spec.Project = "team-b"
+	assert.Nil(t, c.Update(context.Background(), reg))
+	frozen = true
+	_, err = cr.Reconcile(context.Background(), regReq)
+	assert.Nil(t, err)
+	frozen = false
+	assert.Nil(t, c.Delete(context.Background(), reg))
+	frozen, EnableGarbageCollection = true, true
+	_, err = cr.Reconcile(context.Background(), regReq)
+	assert.Nil(t, err)
+	EnableGarbageCollection = oldConf.EnableGarbageCollection
+
+	// Runtime configuration changes moving ArgoSecrets to another namespace.
+	config := &ConfigReconciler{Client: c, Log: TestLog, ConfigMap: client.ObjectKeyFromObject(cm), DryRun: true}
+	_, err = config.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	frozen = false
+	assert.Nil(t, c.Create(context.Background(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster-test",
+		Namespace: "argocd-new",
+		Labels:    map[string]string{"capi-to-argocd/cluster-secret-name": "test-kubeconfig", "capi-to-argocd/cluster-namespace": TestNamespace},
+	}}))
+	frozen = true
+	_, err = config.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+
+	// Label migrations.
+	m := &LabelMigration{Client: c, From: "capi2argo/", To: "capi-to-argocd/", Log: TestLog, DryRun: true}
+	assert.Nil(t, m.Migrate(context.Background()))
+
+	// Status ConfigMaps.
+	status := &StatusReporter{Client: c, Name: "caco-status", Log: TestLog, DryRun: true}
+	status.Record(req.NamespacedName, nil)
+	assert.Nil(t, status.Flush(context.Background()))
+
+	assert.Equal(t, 0, writes)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: oldConf.ArgoNamespace}, &corev1.Secret{}))
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-imported-cluster", Namespace: oldConf.ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcileReconciledBy(t *testing.T) {
+	oldConf := ReconcilerIdentity
+	defer func() { ReconcilerIdentity = oldConf }()
+	ReconcilerIdentity = "caco-0"
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "caco-0", argoSecret.Annotations[reconciledByAnnotation])
+
+	// Another replica taking over does not rewrite in-sync ArgoSecrets.
+	ReconcilerIdentity = "caco-1"
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "caco-0", argoSecret.Annotations[reconciledByAnnotation])
+
+	// But records itself on the ArgoSecrets it updates.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "caco-1", argoSecret.Annotations[reconciledByAnnotation])
+}
+
+func TestReconcileConfigHash(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// Reconciling an unchanged config keeps the hash, without updating the ArgoSecret.
+	AssertReconcileIdempotent(t, c, r, req)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	hash := argoSecret.Annotations[configHashAnnotation]
+	assert.Equal(t, configHash(argoSecret.Data["config"]), hash)
+
+	// A config edited out-of-band no longer matches its hash, and is reverted.
+	argoSecret.Data["config"] = []byte(`{"bearerToken":"tampered"}`)
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, hash, argoSecret.Annotations[configHashAnnotation])
+	assert.Equal(t, hash, configHash(argoSecret.Data["config"]))
+
+	// Changing the config changes the hash.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotEqual(t, hash, argoSecret.Annotations[configHashAnnotation])
+	assert.Equal(t, configHash(argoSecret.Data["config"]), argoSecret.Annotations[configHashAnnotation])
+}
+
+func TestReconcileIgnoreConfigFields(t *testing.T) {
+	oldConf := IgnoreConfigFields
+	defer func() { IgnoreConfigFields = oldConf }()
+	IgnoreConfigFields = []string{"cacheHint", "tlsClientConfig.serverName"}
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+
+	// Ignored fields set by ArgoCD are kept, without fighting over them.
+	config := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(argoSecret.Data["config"], &config))
+	config["cacheHint"] = "warm"
+	config["tlsClientConfig"].(map[string]interface{})["serverName"] = "kube.internal"
+	argoSecret.Data["config"], err = json.Marshal(config)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	edited := argoSecret.Data["config"]
+	AssertReconcileIdempotent(t, c, r, req)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, edited, argoSecret.Data["config"])
+
+	// Other fields are still reverted.
+	config["bearerToken"] = "tampered"
+	argoSecret.Data["config"], err = json.Marshal(config)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.NotContains(t, string(argoSecret.Data["config"]), "tampered")
+	assert.NotContains(t, string(argoSecret.Data["config"]), "cacheHint")
+	assert.Equal(t, argoSecret.Annotations[configHashAnnotation], configHash(argoSecret.Data["config"]))
+}
+
+func TestPreserveUnmanaged(t *testing.T) {
+	t.Parallel()
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{
+			"argocd.argoproj.io/secret-type":     "cluster",
+			"capi-to-argocd/owned":               "true",
+			"capi-to-argocd/readonly":            "true",
+			"stale":                              "a",
+			clusterTakenFromClusterKey + "stale": "",
+			"team":                               "platform",
+			"env":                                "user",
+		},
+		Annotations: map[string]string{
+			configEncodingAnnotation: configEncodingGzip,
+			"owner":                  "admin",
+		},
+	}}
+	desired := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{"capi-to-argocd/owned": "true", "env": "desired"},
+	}}
+	preserveUnmanaged(existing, desired)
+	assert.Equal(t, map[string]string{"capi-to-argocd/owned": "true", "env": "desired", "team": "platform"}, desired.Labels)
+	assert.Equal(t, map[string]string{"owner": "admin"}, desired.Annotations)
+}
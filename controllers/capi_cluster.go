@@ -1,16 +1,42 @@
 package controllers
 
 import (
+	"bytes"
+	b64 "encoding/base64"
 	"errors"
+	"fmt"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"slices"
 	"strings"
+	"time"
 )
 
 // CapiClusterSecretType represents the CAPI managed secret type.
 const CapiClusterSecretType corev1.SecretType = "cluster.x-k8s.io/secret"
 
+// CapiSecretTypes are the secret types accepted as CAPI secrets. CAPI types its kubeconfig
+// secrets CapiClusterSecretType since v1alpha3, while v1alpha2 left them Opaque. Opaque is
+// opt-in only, as it would accept any generic secret following the kubeconfig naming.
+var CapiSecretTypes = []corev1.SecretType{CapiClusterSecretType}
+
+// ExtraOwnerLabels accepts source secrets not typed as CAPI secrets when they carry
+// any of these labels (eg. secrets synced by External Secrets Operator).
+var ExtraOwnerLabels map[string]string
+
+// KubeConfigDataKeys are the candidate data keys holding the kubeconfig of a source, in
+// order of preference. The first one present is used.
+var KubeConfigDataKeys = []string{"value"}
+
+// RegisterAllContexts registers every context of kubeconfigs holding several of them, instead
+// of only their first cluster and user.
+var RegisterAllContexts bool
+
+// AllowEmptyUsers accepts kubeconfigs with no users (eg. public CA-only endpoints), whose
+// credentials are supplied elsewhere.
+var AllowEmptyUsers bool
+
 // CapiCluster is an one-on-one representation of KubeConfig fields.
 type CapiCluster struct {
 	Name       string     `yaml:"name"`
@@ -24,6 +50,19 @@ type KubeConfig struct {
 	Kind       string    `yaml:"kind"`
 	Clusters   []Cluster `yaml:"clusters"`
 	Users      []User    `yaml:"users"`
+	Contexts   []Context `yaml:"contexts"`
+}
+
+// Context represents kubeconfig.[]Contexts fields.
+type Context struct {
+	Name    string      `yaml:"name"`
+	Context ContextInfo `yaml:"context"`
+}
+
+// ContextInfo represents kubeconfig.[]Contexts.Context fields.
+type ContextInfo struct {
+	Cluster string `yaml:"cluster"`
+	User    string `yaml:"user"`
 }
 
 // Cluster represents kubeconfig.[]Clusters.Cluster fields.
@@ -34,8 +73,9 @@ type Cluster struct {
 
 // ClusterInfo represents kubeconfig.[]Clusters.Cluster.Clusterinfo fields.
 type ClusterInfo struct {
-	CaData string `yaml:"certificate-authority-data"`
-	Server string `yaml:"server"`
+	CaData                string `yaml:"certificate-authority-data"`
+	Server                string `yaml:"server"`
+	InsecureSkipTLSVerify bool   `yaml:"insecure-skip-tls-verify"`
 }
 
 // User represents kubeconfig.[]Users fields.
@@ -48,7 +88,8 @@ type User struct {
 type UserInfo struct {
 	CertData *string `yaml:"client-certificate-data,omitempty"`
 	KeyData  *string `yaml:"client-key-data,omitempty"`
-	Token    *string `yaml:"token,omitempty"`
+	// Token is the raw bearer token, taken along into the ArgoCD config as-is.
+	Token *string `yaml:"token,omitempty"`
 }
 
 // NewCapiCluster returns an empty CapiCluster type.
@@ -65,25 +106,138 @@ func (c *CapiCluster) Unmarshal(s *corev1.Secret) error {
 	if err := ValidateCapiSecret(s); err != nil {
 		return err
 	}
-	err := yaml.Unmarshal(s.Data["value"], &c.KubeConfig)
-	if err != nil || len(c.KubeConfig.Clusters) == 0 || len(c.KubeConfig.Users) == 0 || c.KubeConfig.APIVersion != "v1" || c.KubeConfig.Kind != "Config" {
+	return c.UnmarshalData(SecretData(s))
+}
+
+// UnmarshalData parses the KubeConfig stored under the first present KubeConfigDataKeys key
+// of data into CapiCluster type.
+func (c *CapiCluster) UnmarshalData(data map[string][]byte) error {
+	v, ok := kubeConfigData(data)
+	if !ok {
+		return errors.New("wrong secret key")
+	}
+	return c.UnmarshalKubeConfig(v)
+}
+
+// UnmarshalKubeConfig parses raw KubeConfig data into CapiCluster type.
+func (c *CapiCluster) UnmarshalKubeConfig(data []byte) error {
+	defer func(start time.Time) {
+		kubeConfigParseSeconds.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	err := yaml.Unmarshal(decodeKubeConfig(data), &c.KubeConfig)
+	if err != nil || len(c.KubeConfig.Clusters) == 0 || (len(c.KubeConfig.Users) == 0 && !AllowEmptyUsers) || c.KubeConfig.APIVersion != "v1" || c.KubeConfig.Kind != "Config" {
 		return errors.New("invalid KubeConfig")
 
 	}
 	return nil
 }
 
+// SplitContexts returns one CapiCluster per context of the KubeConfig, each holding only the
+// cluster and user of its context, along with the context names.
+func (c *CapiCluster) SplitContexts() ([]*CapiCluster, []string, error) {
+	clusters := make([]*CapiCluster, 0, len(c.KubeConfig.Contexts))
+	names := make([]string, 0, len(c.KubeConfig.Contexts))
+	for _, ctx := range c.KubeConfig.Contexts {
+		split := NewCapiCluster(c.Name, c.Namespace)
+		split.KubeConfig = KubeConfig{APIVersion: c.KubeConfig.APIVersion, Kind: c.KubeConfig.Kind, Contexts: []Context{ctx}}
+		for _, cluster := range c.KubeConfig.Clusters {
+			if cluster.Name == ctx.Context.Cluster {
+				split.KubeConfig.Clusters = []Cluster{cluster}
+				break
+			}
+		}
+		for _, user := range c.KubeConfig.Users {
+			if user.Name == ctx.Context.User {
+				split.KubeConfig.Users = []User{user}
+				break
+			}
+		}
+		if len(split.KubeConfig.Clusters) == 0 || (len(split.KubeConfig.Users) == 0 && !AllowEmptyUsers) {
+			return nil, nil, fmt.Errorf("context %q references an unknown cluster or user", ctx.Name)
+		}
+		clusters = append(clusters, split)
+		names = append(names, ctx.Name)
+	}
+	return clusters, names, nil
+}
+
 // ValidateCapiSecret validates that we got proper defined types for a given secret.
 func ValidateCapiSecret(s *corev1.Secret) error {
-	if s.Type != CapiClusterSecretType {
+	if !isCapiSecretType(s.Type) && !hasExtraOwnerLabel(s) {
 		return errors.New("wrong secret type")
 	}
-	if _, ok := s.Data["value"]; !ok {
+	if _, ok := kubeConfigData(SecretData(s)); !ok {
 		return errors.New("wrong secret key")
 	}
 	return nil
 }
 
+// isCapiSecretType returns true when t is one of CapiSecretTypes.
+func isCapiSecretType(t corev1.SecretType) bool {
+	return slices.Contains(CapiSecretTypes, t)
+}
+
+// kubeConfigData returns the value of the first KubeConfigDataKeys key present in data.
+func kubeConfigData(data map[string][]byte) ([]byte, bool) {
+	for _, key := range KubeConfigDataKeys {
+		if v, ok := data[key]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// decodeKubeConfig returns the kubeconfig of data, decoding it when stored base64-encoded a
+// second time, as some controllers do when writing raw strings instead of Data bytes.
+// A YAML kubeconfig is never valid base64, so plain kubeconfigs are returned as-is.
+func decodeKubeConfig(data []byte) []byte {
+	decoded, err := b64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(decoded) == 0 {
+		return data
+	}
+	return decoded
+}
+
+// SecretData returns the data of a Secret, merging StringData over Data the way the API
+// server does on write, for secrets that were never round-tripped through it.
+func SecretData(s *corev1.Secret) map[string][]byte {
+	if len(s.StringData) == 0 {
+		return s.Data
+	}
+	data := make(map[string][]byte, len(s.Data)+len(s.StringData))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	for k, v := range s.StringData {
+		data[k] = []byte(v)
+	}
+	return data
+}
+
+// ConfigMapData merges the string and binary data of a ConfigMap, so that it can be
+// handled the same way as Secret data.
+func ConfigMapData(cm *corev1.ConfigMap) map[string][]byte {
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	return data
+}
+
+// hasExtraOwnerLabel returns true when the secret carries any of ExtraOwnerLabels.
+func hasExtraOwnerLabel(s *corev1.Secret) bool {
+	for k, v := range ExtraOwnerLabels {
+		if l, ok := s.Labels[k]; ok && l == v {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateCapiNaming validates CAPI kubeconfig naming convention.
 func ValidateCapiNaming(n types.NamespacedName) bool {
 	return strings.HasSuffix(n.Name, "-kubeconfig") && !strings.HasSuffix(n.Name, "-user-kubeconfig")
@@ -145,3 +145,223 @@ func TestValidateCapiSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCapiSecretTypes(t *testing.T) {
+	oldConf := CapiSecretTypes
+	defer func() { CapiSecretTypes = oldConf }()
+	compat := []corev1.SecretType{CapiClusterSecretType, corev1.SecretTypeOpaque}
+	tests := []struct {
+		testName          string
+		testTypes         []corev1.SecretType
+		testType          corev1.SecretType
+		testExpectedError bool
+	}{
+		{"test default with v1alpha2 type", oldConf, corev1.SecretTypeOpaque, true},
+		{"test default with v1alpha3 type", oldConf, CapiClusterSecretType, false},
+		{"test default with v1alpha4 type", oldConf, CapiClusterSecretType, false},
+		{"test default with v1beta1 type", oldConf, CapiClusterSecretType, false},
+		{"test compatibility with v1alpha2 type", compat, corev1.SecretTypeOpaque, false},
+		{"test compatibility with v1alpha3 type", compat, CapiClusterSecretType, false},
+		{"test compatibility with v1alpha4 type", compat, CapiClusterSecretType, false},
+		{"test compatibility with v1beta1 type", compat, CapiClusterSecretType, false},
+		{"test compatibility with other type", compat, corev1.SecretTypeDockerConfigJson, true},
+		{"test custom type only", []corev1.SecretType{"example.com/kubeconfig"}, CapiClusterSecretType, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			CapiSecretTypes = tt.testTypes
+			s := MockCapiSecret(validMock, validType, validKey, name, namespace)
+			s.Type = tt.testType
+			if tt.testExpectedError {
+				assert.EqualError(t, ValidateCapiSecret(s), "wrong secret type")
+			} else {
+				assert.Nil(t, ValidateCapiSecret(s))
+			}
+		})
+	}
+}
+
+func MockExternalSecret(name string, namespace string) *corev1.Secret {
+	s := MockCapiSecret(validMock, validType, validKey, name, namespace)
+	s.Type = corev1.SecretTypeOpaque
+	s.Labels = map[string]string{
+		"reconcile.external-secrets.io/managed": "true",
+	}
+	return s
+}
+
+func TestValidateCapiSecretExtraOwnerLabels(t *testing.T) {
+	tests := []struct {
+		testName             string
+		testMock             *corev1.Secret
+		testExtraOwnerLabels map[string]string
+		testExpectedError    bool
+	}{
+		{"test external secret without extra owner labels", MockExternalSecret(name, namespace), nil, true},
+		{"test external secret with matching extra owner labels", MockExternalSecret(name, namespace),
+			map[string]string{"reconcile.external-secrets.io/managed": "true"}, false},
+		{"test external secret with non-matching extra owner labels", MockExternalSecret(name, namespace),
+			map[string]string{"reconcile.external-secrets.io/managed": "false"}, true},
+		{"test capi secret with extra owner labels", MockCapiSecret(validMock, validType, validKey, name, namespace),
+			map[string]string{"reconcile.external-secrets.io/managed": "true"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			oldConf := ExtraOwnerLabels
+			ExtraOwnerLabels = tt.testExtraOwnerLabels
+			err := ValidateCapiSecret(tt.testMock)
+			ExtraOwnerLabels = oldConf
+			if tt.testExpectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigMapData(t *testing.T) {
+	t.Parallel()
+	cm := &corev1.ConfigMap{
+		Data:       map[string]string{"value": "text", "shared": "text"},
+		BinaryData: map[string][]byte{"binary": []byte("bin"), "shared": []byte("bin")},
+	}
+	assert.Equal(t, map[string][]byte{
+		"value":  []byte("text"),
+		"binary": []byte("bin"),
+		"shared": []byte("text"),
+	}, ConfigMapData(cm))
+}
+
+func TestUnmarshalObservesParseDuration(t *testing.T) {
+	samples := MockHistogramCount(kubeConfigParseSeconds)
+	c := NewCapiCluster(name, namespace)
+	assert.Nil(t, c.Unmarshal(MockCapiSecret(validMock, validType, validKey, name, namespace)))
+	assert.Equal(t, samples+1, MockHistogramCount(kubeConfigParseSeconds))
+
+	// Invalid kubeconfigs are timed too.
+	assert.NotNil(t, c.UnmarshalKubeConfig([]byte("tester")))
+	assert.Equal(t, samples+2, MockHistogramCount(kubeConfigParseSeconds))
+}
+
+func TestUnmarshalEmptyUsers(t *testing.T) {
+	oldConf := AllowEmptyUsers
+	defer func() { AllowEmptyUsers = oldConf }()
+
+	kubeConfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: kube-cluster-test
+  cluster:
+    certificate-authority-data: dGVzdGVyCg==
+    server: https://kube-cluster-test.domain.com:6443
+users: []
+`)
+
+	AllowEmptyUsers = false
+	c := NewCapiCluster(name, namespace)
+	assert.EqualError(t, c.UnmarshalKubeConfig(kubeConfig), "invalid KubeConfig")
+
+	AllowEmptyUsers = true
+	c = NewCapiCluster(name, namespace)
+	assert.Nil(t, c.UnmarshalKubeConfig(kubeConfig))
+
+	a, err := NewArgoCluster(c, MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", namespace), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", a.ClusterServer)
+	assert.Equal(t, "dGVzdGVyCg==", *a.ClusterConfig.TLSClientConfig.CaData)
+	assert.Nil(t, a.ClusterConfig.BearerToken)
+	assert.Nil(t, a.ClusterConfig.TLSClientConfig.CertData)
+	assert.Nil(t, a.ClusterConfig.TLSClientConfig.KeyData)
+}
+
+func TestUnmarshalKubeConfigDataKeys(t *testing.T) {
+	oldConf := KubeConfigDataKeys
+	defer func() { KubeConfigDataKeys = oldConf }()
+	KubeConfigDataKeys = []string{"value", "kubeconfig", "config"}
+
+	valid := MockCapiSecret(validMock, validType, validKey, name, namespace).Data["value"]
+	tests := []struct {
+		testName          string
+		testMock          map[string][]byte
+		testExpectedError bool
+	}{
+		{"test with first key", map[string][]byte{"value": valid}, false},
+		{"test with fallback key", map[string][]byte{"kubeconfig": valid}, false},
+		{"test with last fallback key", map[string][]byte{"config": valid}, false},
+		{"test with preferred key shadowing a fallback", map[string][]byte{"kubeconfig": valid, "config": []byte("tester")}, false},
+		{"test with preferred key invalid", map[string][]byte{"value": []byte("tester"), "kubeconfig": valid}, true},
+		{"test with no candidate key", map[string][]byte{"tester": valid}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s := MockCapiSecret(validMock, validType, validKey, name, namespace)
+			s.Data = tt.testMock
+			c := NewCapiCluster(name, namespace)
+			err := c.Unmarshal(s)
+			if tt.testExpectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, "kube-cluster-test", c.KubeConfig.Clusters[0].Name)
+			}
+		})
+	}
+}
+
+func TestUnmarshalStringData(t *testing.T) {
+	t.Parallel()
+	valid := MockCapiSecret(validMock, validType, validKey, name, namespace).Data["value"]
+	expected := NewCapiCluster(name, namespace)
+	assert.Nil(t, expected.UnmarshalData(map[string][]byte{"value": valid}))
+
+	tests := []struct {
+		testName string
+		testMock func(s *corev1.Secret)
+	}{
+		{"test with data", func(s *corev1.Secret) {}},
+		{"test with stringData", func(s *corev1.Secret) {
+			s.Data = nil
+			s.StringData = map[string]string{"value": string(valid)}
+		}},
+		{"test with stringData over stale data", func(s *corev1.Secret) {
+			s.Data = map[string][]byte{"value": []byte("tester")}
+			s.StringData = map[string]string{"value": string(valid)}
+		}},
+		{"test with base64 encoded data", func(s *corev1.Secret) {
+			s.Data = map[string][]byte{"value": []byte(b64.StdEncoding.EncodeToString(valid) + "\n")}
+		}},
+		{"test with base64 encoded stringData", func(s *corev1.Secret) {
+			s.Data = nil
+			s.StringData = map[string]string{"value": b64.StdEncoding.EncodeToString(valid)}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			s := MockCapiSecret(validMock, validType, validKey, name, namespace)
+			tt.testMock(s)
+			c := NewCapiCluster(name, namespace)
+			assert.Nil(t, c.Unmarshal(s))
+			assert.Equal(t, expected, c)
+		})
+	}
+}
+
+func TestSplitContexts(t *testing.T) {
+	t.Parallel()
+	c := NewCapiCluster(name, namespace)
+	assert.Nil(t, c.UnmarshalKubeConfig([]byte(mockMultiContextKubeConfig)))
+
+	split, names, err := c.SplitContexts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"admin@a", "admin@b", "viewer@b"}, names)
+	if assert.Len(t, split, 3) {
+		assert.Equal(t, "b", split[2].KubeConfig.Clusters[0].Name)
+		assert.Equal(t, "viewer", split[2].KubeConfig.Users[0].Name)
+	}
+
+	c.KubeConfig.Contexts[0].Context.User = "unknown"
+	_, _, err = c.SplitContexts()
+	assert.NotNil(t, err)
+}
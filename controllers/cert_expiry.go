@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"crypto/x509"
+	b64 "encoding/base64"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// CertExpiryWarning is how long before its client certificate expires a kubeconfig is
+// logged about. Zero disables the warning.
+var CertExpiryWarning = 7 * 24 * time.Hour
+
+// parseCertNotAfter returns the expiry of the base64 PEM client certificate of a kubeconfig.
+func parseCertNotAfter(certData string) (time.Time, error) {
+	raw, err := b64.StdEncoding.DecodeString(certData)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return time.Time{}, errors.New("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// observeCertExpiry exports the expiry of the client certificate of cluster, and warns
+// when it is within CertExpiryWarning. Kubeconfigs without a certificate are ignored.
+func observeCertExpiry(log logr.Logger, cluster string, certData *string) {
+	if certData == nil || *certData == "" {
+		return
+	}
+	notAfter, err := parseCertNotAfter(*certData)
+	if err != nil {
+		log.V(1).Info("Failed to parse kubeconfig client certificate", "error", err.Error())
+		return
+	}
+	kubeConfigCertExpirySeconds.WithLabelValues(cluster).Set(float64(notAfter.Unix()))
+	if remaining := time.Until(notAfter); CertExpiryWarning > 0 && remaining < CertExpiryWarning {
+		log.Info("Kubeconfig client certificate expires soon", "cluster", cluster, "notAfter", notAfter.UTC().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	b64 "encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockCertData returns a base64 PEM self-signed client certificate expiring at notAfter.
+func MockCertData(t *testing.T, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kubernetes-admin"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	return b64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestObserveCertExpiry(t *testing.T) {
+	oldConf := CertExpiryWarning
+	defer func() { CertExpiryWarning = oldConf }()
+	CertExpiryWarning = 24 * time.Hour
+
+	tests := []struct {
+		testName        string
+		testMock        time.Time
+		testExpectedLog bool
+	}{
+		{"test with near-expiry cert", time.Now().Add(time.Hour), true},
+		{"test with expired cert", time.Now().Add(-time.Hour), true},
+		{"test with far-expiry cert", time.Now().Add(30 * 24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			log, sink := NewMockLogger(0)
+			certData := MockCertData(t, tt.testMock)
+			observeCertExpiry(log, "test/"+tt.testName, &certData)
+
+			m := &dto.Metric{}
+			assert.Nil(t, kubeConfigCertExpirySeconds.WithLabelValues("test/"+tt.testName).Write(m))
+			assert.Equal(t, float64(tt.testMock.Unix()), m.GetGauge().GetValue())
+			if tt.testExpectedLog {
+				assert.Contains(t, sink.Messages(), "Kubeconfig client certificate expires soon")
+			} else {
+				assert.Empty(t, sink.Messages())
+			}
+		})
+	}
+
+	// Kubeconfigs without a parseable certificate are ignored.
+	log, sink := NewMockLogger(0)
+	invalid := b64.StdEncoding.EncodeToString([]byte("tester"))
+	observeCertExpiry(log, "test/invalid", &invalid)
+	observeCertExpiry(log, "test/none", nil)
+	assert.Empty(t, sink.Messages())
+}
+
+func TestNewArgoClusterCertExpiry(t *testing.T) {
+	c := MockCapiCluster("expiring", "test")
+	notAfter := time.Now().Add(time.Hour)
+	certData := MockCertData(t, notAfter)
+	c.KubeConfig.Users[0].User.CertData = &certData
+
+	_, err := NewArgoCluster(c, MockCapiSecret(validMock, validType, validKey, "expiring-kubeconfig", "test"), nil)
+	assert.Nil(t, err)
+	m := &dto.Metric{}
+	assert.Nil(t, kubeConfigCertExpirySeconds.WithLabelValues("test/expiring").Write(m))
+	assert.Equal(t, float64(notAfter.Unix()), m.GetGauge().GetValue())
+}
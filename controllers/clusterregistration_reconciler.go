@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	capi2argov1alpha1 "github.com/dntosas/capi2argo-cluster-operator/api/v1alpha1"
+)
+
+const (
+	clusterRegistrationNameLabel = "capi-to-argocd/cluster-registration-name"
+
+	// registrationLabelsAnnotation records the labels of an ArgoSecret set from the spec of
+	// its ClusterRegistration, see recordLabels.
+	registrationLabelsAnnotation = "capi-to-argocd/registration-labels"
+
+	// kubeConfigSecretRefIndex indexes ClusterRegistrations by the name of their kubeconfig
+	// Secret.
+	kubeConfigSecretRefIndex = "spec.kubeConfigSecretRef.name"
+)
+
+// ClusterRegistrationReconciler reconciles ClusterRegistration objects into ArgoCD cluster secrets.
+type ClusterRegistrationReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Resync optionally enqueues ClusterRegistrations on demand (eg. on configuration changes).
+	Resync <-chan event.GenericEvent
+	// DryRun logs the ArgoSecrets that would be created, updated or deleted instead of
+	// writing them.
+	DryRun bool
+}
+
+// +kubebuilder:rbac:groups=capi-to-argocd.io,resources=clusterregistrations,verbs=get;list;watch
+
+// Reconcile converts a ClusterRegistration and its kubeconfig secret into an ArgoSecret.
+func (r *ClusterRegistrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("clusterregistration", req.NamespacedName)
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	if paused(ctx, r.Client, log) {
+		log.Info("Reconciles are paused, requeueing...", "namespace", OperatorNamespace, "after", PausedRequeueAfter)
+		return ctrl.Result{RequeueAfter: PausedRequeueAfter}, nil
+	}
+
+	var reg capi2argov1alpha1.ClusterRegistration
+	err := r.Get(ctx, req.NamespacedName, &reg)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !EnableGarbageCollection {
+			return ctrl.Result{}, nil
+		}
+		// ClusterRegistration is gone, clean up its ArgoSecrets.
+		secretList := &corev1.SecretList{}
+		if err := r.List(ctx, secretList, client.MatchingLabels{
+			clusterRegistrationNameLabel:       req.Name,
+			"capi-to-argocd/cluster-namespace": req.Namespace,
+		}); err != nil {
+			log.Error(err, "Failed to list ArgoSecrets")
+			return ctrl.Result{}, err
+		}
+		for i := range secretList.Items {
+			if isProtected(&secretList.Items[i]) {
+				log.Info("ArgoSecret is protected, skipping deletion...", "argoSecret", client.ObjectKeyFromObject(&secretList.Items[i]))
+				continue
+			}
+			if r.DryRun {
+				log.Info("Dry-run, would delete ArgoSecret", "argoSecret", client.ObjectKeyFromObject(&secretList.Items[i]))
+				continue
+			}
+			if err := r.Delete(ctx, &secretList.Items[i]); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Failed to delete ArgoSecret")
+				return ctrl.Result{}, err
+			}
+			log.Info("Deleted successfully of ArgoSecret")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	var kubeConfigSecret corev1.Secret
+	ref := reg.Spec.KubeConfigSecretRef
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: req.Namespace}, &kubeConfigSecret); err != nil {
+		log.Error(err, "Failed to fetch KubeConfig secret", "secret", ref.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Without an explicit key, the kubeconfig is looked up under KubeConfigDataKeys.
+	capiCluster := NewCapiCluster(reg.Name, reg.Namespace)
+	data := SecretData(&kubeConfigSecret)
+	if ref.Key != "" {
+		err = capiCluster.UnmarshalKubeConfig(data[ref.Key])
+	} else {
+		err = capiCluster.UnmarshalData(data)
+	}
+	if err != nil {
+		log.Error(err, "Failed to unmarshal KubeConfig", "secret", ref.Name, "key", ref.Key)
+		return ctrl.Result{}, err
+	}
+
+	// Name ArgoSecrets after the ClusterRegistration, not the referenced secret.
+	argoCluster, err := NewArgoCluster(capiCluster, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: reg.Name, Namespace: reg.Namespace}}, nil)
+	if err != nil {
+		log.Error(err, "Failed to construct ArgoCluster")
+		return ctrl.Result{}, err
+	}
+	// Labels prefixed with managedKeyPrefix are CACO's own, eg. the ownership ones collision
+	// checks and garbage collection rely on, and can not be set from the spec.
+	argoCluster.ClusterLabels = map[string]string{}
+	for key, value := range reg.Spec.Labels {
+		if strings.HasPrefix(key, managedKeyPrefix) {
+			log.Info("Ignoring reserved label of ClusterRegistration", "label", key)
+			continue
+		}
+		argoCluster.ClusterLabels[key] = value
+	}
+	specLabels := slices.Collect(maps.Keys(argoCluster.ClusterLabels))
+	argoCluster.ClusterLabels[clusterRegistrationNameLabel] = reg.Name
+	argoCluster.ClusterLabels["capi-to-argocd/cluster-namespace"] = reg.Namespace
+	argoCluster.Project = reg.Spec.Project
+	argoCluster.Namespaces = reg.Spec.Namespaces
+	if err := setOutOfBandCredentials(ctx, r.Client, argoCluster); err != nil {
+		log.Error(err, "Failed to set ArgoCluster credentials", "credentials", CredentialsSecret)
+		return ctrl.Result{}, err
+	}
+	if err := setCABundle(ctx, r.Client, argoCluster); err != nil {
+		log.Error(err, "Failed to set ArgoCluster CA bundle", "configmap", CABundleConfigMap, "key", CABundleKey)
+		return ctrl.Result{}, err
+	}
+	if err := ValidateSingleNamespace(reg.Namespace, argoCluster.NamespacedName.Namespace); err != nil {
+		log.Error(err, "Refusing to sync ClusterRegistration", "namespace", SingleNamespace)
+		return ctrl.Result{}, err
+	}
+
+	log = log.WithValues("cluster", argoCluster.NamespacedName)
+	argoSecret, err := argoCluster.ConvertToSecret()
+	if err != nil {
+		log.Error(err, "Failed to convert ArgoCluster to ArgoSecret")
+		return ctrl.Result{}, err
+	}
+	recordLabels(argoSecret, registrationLabelsAnnotation, specLabels)
+
+	var existingSecret corev1.Secret
+	err = r.Get(ctx, argoCluster.NamespacedName, &existingSecret)
+	if errors.IsNotFound(err) {
+		if r.DryRun {
+			log.Info("Dry-run, would create ArgoSecret", "labels", renderLabels(argoSecret.Labels))
+			return ctrl.Result{}, nil
+		}
+		if err := r.Create(ctx, argoSecret); err != nil {
+			log.Error(err, "Failed to create ArgoSecret")
+			return ctrl.Result{}, err
+		}
+		log.Info("Created new ArgoSecret")
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to fetch ArgoSecret to check if exists")
+		return ctrl.Result{}, err
+	}
+
+	if err := ValidateObjectOwner(existingSecret); err != nil {
+		log.Info("Not managed by Controller, skipping...")
+		return ctrl.Result{}, nil
+	}
+	// ClusterRegistrations of the same name in different namespaces share the ArgoSecret name.
+	if existingSecret.Labels[clusterRegistrationNameLabel] != reg.Name ||
+		existingSecret.Labels["capi-to-argocd/cluster-namespace"] != reg.Namespace {
+		log.Error(ErrArgoSecretNameCollision, "Skipping ClusterRegistration")
+		return ctrl.Result{}, nil
+	}
+
+	// Labels and annotations set by others, eg. an admin, are kept. The ones set from the spec
+	// are tracked by registrationLabelsAnnotation, so that the ones dropped from it are removed.
+	original := existingSecret.DeepCopy()
+	existingSecret.Data = argoSecret.Data
+	for key := range argoSecret.Labels {
+		syncKey(existingSecret.Labels, argoSecret.Labels, key)
+	}
+	syncRecordedLabels(log, "registration", &existingSecret, argoSecret, registrationLabelsAnnotation)
+	syncRecordedLabels(log, "ApplicationSet", &existingSecret, argoSecret, applicationSetLabelsAnnotation)
+	syncRecordedLabels(log, "templated", &existingSecret, argoSecret, templatedLabelsAnnotation)
+	for _, key := range append(slices.Collect(maps.Keys(argoSecret.Annotations)), configEncodingAnnotation) {
+		syncKey(existingSecret.Annotations, argoSecret.Annotations, key)
+	}
+	if reflect.DeepEqual(original, &existingSecret) {
+		log.Info("ArgoSecret is in-sync with ClusterRegistration, skipping...")
+		return ctrl.Result{}, nil
+	}
+	if r.DryRun {
+		log.Info("Dry-run, would update out-of-sync ArgoSecret", "diff", diffSummary(original, &existingSecret))
+		return ctrl.Result{}, nil
+	}
+	if err := r.Update(ctx, &existingSecret); err != nil {
+		log.Error(err, "Failed to update ArgoSecret")
+		return ctrl.Result{}, err
+	}
+	log.Info("Updated successfully of ArgoSecret")
+	return ctrl.Result{}, nil
+}
+
+// kubeConfigSecretRefName is the indexer of kubeConfigSecretRefIndex.
+func kubeConfigSecretRefName(o client.Object) []string {
+	return []string{o.(*capi2argov1alpha1.ClusterRegistration).Spec.KubeConfigSecretRef.Name}
+}
+
+// kubeConfigSecretToRegistrations maps a Secret to the ClusterRegistrations referencing it,
+// so that rotated credentials are synced right away.
+func (r *ClusterRegistrationReconciler) kubeConfigSecretToRegistrations(ctx context.Context, o client.Object) []reconcile.Request {
+	regList := &capi2argov1alpha1.ClusterRegistrationList{}
+	if err := r.List(ctx, regList, client.InNamespace(o.GetNamespace()), client.MatchingFields{kubeConfigSecretRefIndex: o.GetName()}); err != nil {
+		r.Log.Error(err, "Failed to list ClusterRegistrations of KubeConfig secret", "secret", client.ObjectKeyFromObject(o))
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(regList.Items))
+	for i := range regList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&regList.Items[i])})
+	}
+	return requests
+}
+
+// SetupWithManager registers the ClusterRegistration controller, along with a watch of the
+// kubeconfig Secrets referenced by ClusterRegistrations.
+func (r *ClusterRegistrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &capi2argov1alpha1.ClusterRegistration{}, kubeConfigSecretRefIndex, kubeConfigSecretRefName); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&capi2argov1alpha1.ClusterRegistration{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.kubeConfigSecretToRegistrations))
+	if r.Resync != nil {
+		b = b.WatchesRawSource(source.Channel(r.Resync, &handler.EnqueueRequestForObject{}))
+	}
+	return b.Complete(r)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	capi2argov1alpha1 "github.com/dntosas/capi2argo-cluster-operator/api/v1alpha1"
+)
+
+func MockClusterRegistration(name string, namespace string, secretName string) *capi2argov1alpha1.ClusterRegistration {
+	return &capi2argov1alpha1.ClusterRegistration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capi2argov1alpha1.ClusterRegistrationSpec{
+			KubeConfigSecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  "kubeconfig",
+			},
+			Project:    "team-a",
+			Namespaces: []string{"app-1", "app-2"},
+			Labels:     map[string]string{"env": "prod"},
+		},
+	}
+}
+
+func TestClusterRegistrationReconcile(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+
+	kubeConfig := MockCapiSecret(validMock, validType, validKey, "imported", TestNamespace)
+	kubeConfig.Type = corev1.SecretTypeOpaque
+	kubeConfig.Data = map[string][]byte{"kubeconfig": kubeConfig.Data["value"]}
+	reg := MockClusterRegistration("imported-cluster", TestNamespace, "imported")
+
+	c := NewMockClient(kubeConfig, reg)
+	r := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+	req := MockReconcileReq(reg.Name, reg.Namespace)
+	argoKey := types.NamespacedName{Name: "cluster-imported-cluster", Namespace: ArgoNamespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "team-a", string(argoSecret.Data["project"]))
+	assert.Equal(t, "app-1,app-2", string(argoSecret.Data["namespaces"]))
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["server"]))
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+	assert.Equal(t, "true", argoSecret.Labels["capi-to-argocd/owned"])
+	assert.Equal(t, reg.Name, argoSecret.Labels[clusterRegistrationNameLabel])
+
+	assert.Equal(t, "env", argoSecret.Annotations[registrationLabelsAnnotation])
+
+	// Spec changes are synced, keeping the labels and annotations set by others.
+	argoSecret.Labels["team"] = "platform"
+	argoSecret.Annotations["note"] = "imported by hand"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	reg.Spec.Project = "team-b"
+	delete(reg.Spec.Labels, "env")
+	assert.Nil(t, c.Update(context.Background(), reg))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "team-b", string(argoSecret.Data["project"]))
+	assert.NotContains(t, argoSecret.Labels, "env")
+	assert.NotContains(t, argoSecret.Annotations, registrationLabelsAnnotation)
+	assert.Equal(t, "platform", argoSecret.Labels["team"])
+	assert.Equal(t, "imported by hand", argoSecret.Annotations["note"])
+	AssertReconcileIdempotent(t, c, r, req)
+
+	// Rotated credentials refresh the config hash.
+	kubeConfig.Data["kubeconfig"] = bytes.Replace(kubeConfig.Data["kubeconfig"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), kubeConfig))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Contains(t, string(argoSecret.Data["config"]), "rotated")
+	assert.Equal(t, configHash(argoSecret.Data["config"]), argoSecret.Annotations[configHashAnnotation])
+
+	// Deleting the ClusterRegistration removes its ArgoSecret once garbage collection is
+	// enabled, unless protected.
+	assert.Nil(t, c.Delete(context.Background(), reg))
+	EnableGarbageCollection = false
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+
+	EnableGarbageCollection = true
+	argoSecret.Annotations[protectedAnnotation] = "true"
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+
+	delete(argoSecret.Annotations, protectedAnnotation)
+	assert.Nil(t, c.Update(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, argoSecret))
+}
+
+func TestClusterRegistrationKubeConfigSecretWatch(t *testing.T) {
+	kubeConfig := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "imported", Namespace: TestNamespace}}
+	c := NewMockClient(
+		MockClusterRegistration("imported-cluster", TestNamespace, "imported"),
+		MockClusterRegistration("imported-again", TestNamespace, "imported"),
+		MockClusterRegistration("other-cluster", TestNamespace, "other"),
+		MockClusterRegistration("imported-cluster", "other-namespace", "imported"),
+	)
+	c.Indexers = map[string]client.IndexerFunc{kubeConfigSecretRefIndex: kubeConfigSecretRefName}
+	r := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+
+	// Only the ClusterRegistrations referencing the Secret, from its namespace, are synced.
+	assert.ElementsMatch(t, []reconcile.Request{
+		MockReconcileReq("imported-cluster", TestNamespace),
+		MockReconcileReq("imported-again", TestNamespace),
+	}, r.kubeConfigSecretToRegistrations(context.Background(), kubeConfig))
+}
+
+func TestClusterRegistrationReconcileInvalidKubeConfig(t *testing.T) {
+	kubeConfig := MockCapiSecret(!validMock, validType, validKey, "imported", TestNamespace)
+	kubeConfig.Data = map[string][]byte{"kubeconfig": kubeConfig.Data["value"]}
+	reg := MockClusterRegistration("imported-cluster", TestNamespace, "imported")
+
+	r := &ClusterRegistrationReconciler{Client: NewMockClient(kubeConfig, reg), Log: TestLog}
+	_, err := r.Reconcile(context.Background(), MockReconcileReq(reg.Name, reg.Namespace))
+	assert.EqualError(t, err, "invalid KubeConfig")
+}
+
+func TestClusterRegistrationReconcileReservedLabels(t *testing.T) {
+	kubeConfig := MockCapiSecret(validMock, validType, validKey, "imported", TestNamespace)
+	kubeConfig.Data = map[string][]byte{"kubeconfig": kubeConfig.Data["value"]}
+	reg := MockClusterRegistration("imported-cluster", TestNamespace, "imported")
+	reg.Spec.Labels = map[string]string{
+		"env":                              "prod",
+		clusterRegistrationNameLabel:       "hijacked",
+		"capi-to-argocd/cluster-namespace": "other-namespace",
+		"capi-to-argocd/owned":             "false",
+	}
+
+	c := NewMockClient(kubeConfig, reg)
+	r := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+	_, err := r.Reconcile(context.Background(), MockReconcileReq(reg.Name, reg.Namespace))
+	assert.Nil(t, err)
+
+	// Spec labels can not override the ones CACO relies on to own the ArgoSecret.
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-imported-cluster", Namespace: ArgoNamespace}, argoSecret))
+	assert.Equal(t, "prod", argoSecret.Labels["env"])
+	assert.Equal(t, reg.Name, argoSecret.Labels[clusterRegistrationNameLabel])
+	assert.Equal(t, TestNamespace, argoSecret.Labels["capi-to-argocd/cluster-namespace"])
+	assert.Equal(t, "true", argoSecret.Labels["capi-to-argocd/owned"])
+	assert.Equal(t, "env", argoSecret.Annotations[registrationLabelsAnnotation])
+}
+
+func TestClusterRegistrationReconcileNameCollision(t *testing.T) {
+	kubeConfig := MockCapiSecret(validMock, validType, validKey, "imported", TestNamespace)
+	kubeConfig.Data = map[string][]byte{"kubeconfig": kubeConfig.Data["value"]}
+	otherKubeConfig := kubeConfig.DeepCopy()
+	otherKubeConfig.Namespace = "other-namespace"
+	reg := MockClusterRegistration("imported-cluster", TestNamespace, "imported")
+	other := MockClusterRegistration("imported-cluster", "other-namespace", "imported")
+	other.Spec.Project = "team-b"
+
+	c := NewMockClient(kubeConfig, otherKubeConfig, reg, other)
+	r := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+	for _, req := range []reconcile.Request{MockReconcileReq(reg.Name, reg.Namespace), MockReconcileReq(other.Name, other.Namespace)} {
+		_, err := r.Reconcile(context.Background(), req)
+		assert.Nil(t, err)
+	}
+
+	// A ClusterRegistration of the same name in another namespace does not take the ArgoSecret over.
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-imported-cluster", Namespace: ArgoNamespace}, argoSecret))
+	assert.Equal(t, TestNamespace, argoSecret.Labels["capi-to-argocd/cluster-namespace"])
+	assert.Equal(t, "team-a", string(argoSecret.Data["project"]))
+}
@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	capi2argov1alpha1 "github.com/dntosas/capi2argo-cluster-operator/api/v1alpha1"
+)
+
+// configMu guards the runtime configuration against changes applied while reconciling.
+var configMu sync.RWMutex
+
+// RuntimeConfigMap references the ConfigMap the runtime configuration is read from, if any.
+var RuntimeConfigMap types.NamespacedName
+
+// Startup toggles living in main otherwise, kept here to be reported as features.
+var (
+	// EnableDryRun logs the writes of every controller instead of making them.
+	EnableDryRun bool
+	// WatchConfigMaps also reconciles kubeconfig ConfigMaps.
+	WatchConfigMaps bool
+	// EnableClusterRegistrations reconciles ClusterRegistration resources.
+	EnableClusterRegistrations bool
+	// GCOnStartup garbage collects ArgoSecrets orphaned while CACO was not running.
+	GCOnStartup bool
+)
+
+// Config holds the part of CACO configuration that can be changed without restart.
+type Config struct {
+	ArgoNamespace           string
+	EnableGarbageCollection bool
+	EnableNamespacedNames   bool
+}
+
+// CurrentConfig returns a snapshot of the running configuration.
+func CurrentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return Config{
+		ArgoNamespace:           ArgoNamespace,
+		EnableGarbageCollection: EnableGarbageCollection,
+		EnableNamespacedNames:   EnableNamespacedNames,
+	}
+}
+
+// Features returns the feature toggles of c, keyed by their caco_feature_enabled label, along
+// with the toggles set at startup, which never change at runtime.
+func (c Config) Features() map[string]bool {
+	return map[string]bool{
+		"gc":                          c.EnableGarbageCollection,
+		"namespaced_names":            c.EnableNamespacedNames,
+		"dry_run":                     EnableDryRun,
+		"watch_configmaps":            WatchConfigMaps,
+		"cluster_registrations":       EnableClusterRegistrations,
+		"gc_on_startup":               GCOnStartup,
+		"self_registration":           AllowSelfRegistration,
+		"server_templates":            AllowServerTemplates,
+		"register_all_contexts":       RegisterAllContexts,
+		"allow_empty_users":           AllowEmptyUsers,
+		"reject_loopback_servers":     RejectLoopbackServers,
+		"skip_duplicate_servers":      SkipDuplicateServers,
+		"wait_for_ready_condition":    WaitForReadyCondition,
+		"omit_bearer_token":           OmitBearerToken,
+		"sanitize_names":              SanitizeNames,
+		"compress_config":             EnableCompressConfig,
+		"force_ca_configmap":          ForceCABundle,
+		"warn_empty_takealong_values": WarnEmptyTakeAlongValues,
+		"takeover_secret_type":        TakeoverSecretType,
+		"allow_recreate":              AllowRecreate,
+		"exemplars":                   EnableExemplars,
+	}
+}
+
+// RecordFeatures exposes the feature toggles of c through caco_feature_enabled.
+func RecordFeatures(c Config) {
+	for feature, enabled := range c.Features() {
+		v := 0.0
+		if enabled {
+			v = 1
+		}
+		featureEnabled.WithLabelValues(feature).Set(v)
+	}
+}
+
+// ApplyConfig replaces the running configuration and returns the previous one.
+func ApplyConfig(c Config) Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	RecordFeatures(c)
+	old := Config{
+		ArgoNamespace:           ArgoNamespace,
+		EnableGarbageCollection: EnableGarbageCollection,
+		EnableNamespacedNames:   EnableNamespacedNames,
+	}
+	ArgoNamespace = c.ArgoNamespace
+	EnableGarbageCollection = c.EnableGarbageCollection
+	EnableNamespacedNames = c.EnableNamespacedNames
+	return old
+}
+
+// ParseConfigMap overlays the keys of a ConfigMap on top of base. Keys match the
+// environment variables used at startup, and are validated likewise; missing keys keep
+// their base value.
+func ParseConfigMap(cm *corev1.ConfigMap, base Config) (Config, error) {
+	c := base
+	if v, ok := cm.Data["ARGOCD_NAMESPACE"]; ok && v != "" {
+		if err := ValidateArgoNamespaceSettings(v); err != nil {
+			return base, err
+		}
+		c.ArgoNamespace = v
+	}
+	if v, ok := cm.Data["ENABLE_GARBAGE_COLLECTION"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return base, err
+		}
+		c.EnableGarbageCollection = b
+	}
+	if v, ok := cm.Data["ENABLE_NAMESPACED_NAMES"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return base, err
+		}
+		c.EnableNamespacedNames = b
+	}
+	return c, nil
+}
+
+// movedRequeueAfter is how often ArgoSecrets left behind in a previous ArgoNamespace are
+// checked for their replacement in the new one.
+var movedRequeueAfter = 10 * time.Second
+
+// ConfigReconciler watches the CACO ConfigMap and applies its configuration at runtime.
+type ConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	ConfigMap types.NamespacedName
+	// Resync receives an event per CAPI secret whenever the configuration changes.
+	Resync chan<- event.GenericEvent
+	// ConfigMapResync and RegistrationResync optionally receive an event per kubeconfig
+	// ConfigMap and ClusterRegistration likewise.
+	ConfigMapResync    chan<- event.GenericEvent
+	RegistrationResync chan<- event.GenericEvent
+	// DryRun logs the ArgoSecrets that would be deleted instead of deleting them.
+	DryRun bool
+
+	// movedFrom holds the previous ArgoNamespaces still holding ArgoSecrets, see pruneMoved.
+	// The controller runs a single worker, so it needs no locking.
+	movedFrom map[string]bool
+}
+
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+// Reconcile applies the watched ConfigMap and triggers a full re-sync if anything changed.
+func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("configmap", req.NamespacedName)
+
+	var cm corev1.ConfigMap
+	err := r.Get(ctx, req.NamespacedName, &cm)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if err == nil {
+		if err := r.apply(ctx, log, &cm); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return r.pruneMoved(ctx, log)
+}
+
+// apply applies the configuration of cm, if changed, and re-syncs every source.
+func (r *ConfigReconciler) apply(ctx context.Context, log logr.Logger, cm *corev1.ConfigMap) error {
+	current := CurrentConfig()
+	c, err := ParseConfigMap(cm, current)
+	if err != nil {
+		log.Error(err, "Failed to parse ConfigMap")
+		return err
+	}
+	if c == current {
+		return nil
+	}
+
+	old := ApplyConfig(c)
+	log.Info("Applied new configuration", "config", c)
+
+	// ArgoSecrets need to move, the ones left in the previous namespace are pruned once
+	// they were replaced.
+	if old.ArgoNamespace != c.ArgoNamespace {
+		if r.movedFrom == nil {
+			r.movedFrom = map[string]bool{}
+		}
+		r.movedFrom[old.ArgoNamespace] = true
+		delete(r.movedFrom, c.ArgoNamespace)
+	}
+	return r.resync(ctx)
+}
+
+// pruneMoved deletes the ArgoSecrets left in previous ArgoNamespaces once their source has
+// an ArgoSecret in the current one, and requeues until none is left. Protected ArgoSecrets
+// are kept, and nothing is deleted while reconciles are paused.
+func (r *ConfigReconciler) pruneMoved(ctx context.Context, log logr.Logger) (ctrl.Result, error) {
+	if len(r.movedFrom) == 0 {
+		return ctrl.Result{}, nil
+	}
+	if paused(ctx, r.Client, log) {
+		log.Info("Reconciles are paused, requeueing...", "namespace", OperatorNamespace, "after", PausedRequeueAfter)
+		return ctrl.Result{RequeueAfter: PausedRequeueAfter}, nil
+	}
+	to := CurrentConfig().ArgoNamespace
+	for from := range r.movedFrom {
+		secretList := &corev1.SecretList{}
+		if err := r.List(ctx, secretList, client.InNamespace(from), client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+			log.Error(err, "Failed to list ArgoSecrets")
+			return ctrl.Result{}, err
+		}
+		pending := false
+		for i := range secretList.Items {
+			s := &secretList.Items[i]
+			selector := movedSourceSelector(s)
+			if isShadowSecret(s) || selector == nil {
+				continue
+			}
+			replacements := &corev1.SecretList{}
+			if err := r.List(ctx, replacements, client.InNamespace(to), selector); err != nil {
+				log.Error(err, "Failed to list ArgoSecrets")
+				return ctrl.Result{}, err
+			}
+			if len(replacements.Items) == 0 {
+				log.V(1).Info("Moved ArgoSecret is not replaced yet", "secret", client.ObjectKeyFromObject(s))
+				pending = true
+				continue
+			}
+			if isProtected(s) {
+				log.Info("Moved ArgoSecret is protected, skipping deletion...", "secret", client.ObjectKeyFromObject(s))
+				continue
+			}
+			if r.DryRun {
+				log.Info("Dry-run, would delete moved ArgoSecret", "secret", client.ObjectKeyFromObject(s))
+				continue
+			}
+			if err := r.Delete(ctx, s); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Failed to delete ArgoSecret", "secret", s.Name)
+				return ctrl.Result{}, err
+			}
+			log.Info("Deleted successfully of moved ArgoSecret", "secret", client.ObjectKeyFromObject(s))
+		}
+		if !pending {
+			delete(r.movedFrom, from)
+		}
+	}
+	if len(r.movedFrom) > 0 {
+		return ctrl.Result{RequeueAfter: movedRequeueAfter}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// movedSourceSelector matches the ArgoSecrets generated from the same source as s, be it a
+// CAPI secret, a kubeconfig ConfigMap or a ClusterRegistration. It is nil when s tells no
+// source.
+func movedSourceSelector(s *corev1.Secret) client.MatchingLabels {
+	namespace, ok := s.Labels["capi-to-argocd/cluster-namespace"]
+	if !ok {
+		return nil
+	}
+	for _, key := range []string{"capi-to-argocd/cluster-secret-name", clusterRegistrationNameLabel} {
+		if name, ok := s.Labels[key]; ok {
+			return client.MatchingLabels{key: name, "capi-to-argocd/cluster-namespace": namespace}
+		}
+	}
+	return nil
+}
+
+// resync enqueues all CAPI secrets for reconciliation, along with kubeconfig ConfigMaps and
+// ClusterRegistrations when watched. Events are sent in the background, so that a
+// controller not consuming them yet never blocks the reconcile.
+func (r *ConfigReconciler) resync(ctx context.Context) error {
+	if r.Resync != nil {
+		secretList := &corev1.SecretList{}
+		if err := r.List(ctx, secretList); err != nil {
+			return err
+		}
+		var events []event.GenericEvent
+		for i := range secretList.Items {
+			s := &secretList.Items[i]
+			if !isCapiSecretType(s.Type) || !ValidateCapiNaming(client.ObjectKeyFromObject(s)) {
+				continue
+			}
+			events = append(events, event.GenericEvent{Object: s})
+		}
+		go sendEvents(ctx, r.Resync, events)
+	}
+	if r.ConfigMapResync != nil {
+		cmList := &corev1.ConfigMapList{}
+		if err := r.List(ctx, cmList); err != nil {
+			return err
+		}
+		var events []event.GenericEvent
+		for i := range cmList.Items {
+			if ValidateCapiNaming(client.ObjectKeyFromObject(&cmList.Items[i])) {
+				events = append(events, event.GenericEvent{Object: &cmList.Items[i]})
+			}
+		}
+		go sendEvents(ctx, r.ConfigMapResync, events)
+	}
+	if r.RegistrationResync != nil {
+		regList := &capi2argov1alpha1.ClusterRegistrationList{}
+		if err := r.List(ctx, regList); err != nil {
+			return err
+		}
+		var events []event.GenericEvent
+		for i := range regList.Items {
+			events = append(events, event.GenericEvent{Object: &regList.Items[i]})
+		}
+		go sendEvents(ctx, r.RegistrationResync, events)
+	}
+	return nil
+}
+
+// sendEvents sends events to ch until done or ctx is done.
+func sendEvents(ctx context.Context, ch chan<- event.GenericEvent, events []event.GenericEvent) {
+	for _, e := range events {
+		select {
+		case ch <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// configMapCacheByObject limits the cache of ConfigMaps to RuntimeConfigMap and
+// CABundleConfigMap, the only ones read unless kubeconfig ConfigMaps are watched. Field
+// selectors can not match either of two names, so both share their namespace when in the
+// same one.
+func configMapCacheByObject() cache.ByObject {
+	names := map[string][]string{}
+	for _, nn := range []types.NamespacedName{RuntimeConfigMap, CABundleConfigMap} {
+		if nn.Name != "" && !slices.Contains(names[nn.Namespace], nn.Name) {
+			names[nn.Namespace] = append(names[nn.Namespace], nn.Name)
+		}
+	}
+	namespaces := map[string]cache.Config{}
+	for namespace, n := range names {
+		if len(n) == 1 {
+			namespaces[namespace] = cache.Config{FieldSelector: fields.OneTermEqualSelector("metadata.name", n[0])}
+		} else {
+			namespaces[namespace] = cache.Config{}
+		}
+	}
+	return cache.ByObject{Namespaces: namespaces}
+}
+
+// SetupWithManager registers the ConfigMap watch, filtered to the configured ConfigMap. The
+// cache only holds the ConfigMaps CACO reads, see configMapCacheByObject.
+func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("config").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return client.ObjectKeyFromObject(o) == r.ConfigMap
+		}))).
+		Complete(r)
+}
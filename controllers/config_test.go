@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	capi2argov1alpha1 "github.com/dntosas/capi2argo-cluster-operator/api/v1alpha1"
+)
+
+func MockConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "caco-config",
+			Namespace: "caco",
+		},
+		Data: data,
+	}
+}
+
+func TestParseConfigMap(t *testing.T) {
+	t.Parallel()
+	base := Config{ArgoNamespace: "argocd"}
+	tests := []struct {
+		testName           string
+		testMock           *corev1.ConfigMap
+		testExpectedError  bool
+		testExpectedValues Config
+	}{
+		{"test empty configmap", MockConfigMap(nil), false, base},
+		{"test configmap with all keys", MockConfigMap(map[string]string{
+			"ARGOCD_NAMESPACE":          "argocd-new",
+			"ENABLE_GARBAGE_COLLECTION": "true",
+			"ENABLE_NAMESPACED_NAMES":   "true",
+		}), false, Config{ArgoNamespace: "argocd-new", EnableGarbageCollection: true, EnableNamespacedNames: true}},
+		{"test configmap with non-valid bool", MockConfigMap(map[string]string{
+			"ENABLE_GARBAGE_COLLECTION": "tester",
+		}), true, base},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseConfigMap(tt.testMock, base)
+			if tt.testExpectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.testExpectedValues, c)
+		})
+	}
+}
+
+func TestParseConfigMapArgoNamespace(t *testing.T) {
+	oldSingle, oldShadow := SingleNamespace, ShadowNamespace
+	defer func() { SingleNamespace, ShadowNamespace = oldSingle, oldShadow }()
+	base := Config{ArgoNamespace: "argocd"}
+	cm := MockConfigMap(map[string]string{"ARGOCD_NAMESPACE": "argocd-new"})
+
+	// The ArgoCD namespace is validated like at startup.
+	ShadowNamespace = "argocd-new"
+	c, err := ParseConfigMap(cm, base)
+	assert.ErrorIs(t, err, ErrShadowNamespace)
+	assert.Equal(t, base, c)
+
+	ShadowNamespace, SingleNamespace = "", "argocd"
+	c, err = ParseConfigMap(cm, base)
+	assert.ErrorIs(t, err, ErrCrossNamespace)
+	assert.Equal(t, base, c)
+
+	SingleNamespace = ""
+	c, err = ParseConfigMap(cm, base)
+	assert.Nil(t, err)
+	assert.Equal(t, "argocd-new", c.ArgoNamespace)
+}
+
+func TestConfigReconcilerNamespaceChange(t *testing.T) {
+	oldConf := CurrentConfig()
+	defer ApplyConfig(oldConf)
+
+	cm := MockConfigMap(map[string]string{"ARGOCD_NAMESPACE": "argocd-new"})
+	kubeConfigMap := MockKubeConfigMap("cm-kubeconfig", TestNamespace)
+	reg := &capi2argov1alpha1.ClusterRegistration{ObjectMeta: metav1.ObjectMeta{Name: "reg", Namespace: TestNamespace}}
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cm, kubeConfigMap, reg)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	oldKey := types.NamespacedName{Name: "cluster-test", Namespace: oldConf.ArgoNamespace}
+	assert.Nil(t, c.Get(context.Background(), oldKey, &corev1.Secret{}))
+
+	// A ClusterRegistration whose ArgoSecret is protected.
+	protectedKey := types.NamespacedName{Name: "cluster-reg", Namespace: oldConf.ArgoNamespace}
+	assert.Nil(t, c.Create(context.Background(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      protectedKey.Name,
+		Namespace: protectedKey.Namespace,
+		Labels: map[string]string{
+			"capi-to-argocd/owned":             "true",
+			clusterRegistrationNameLabel:       "reg",
+			"capi-to-argocd/cluster-namespace": TestNamespace,
+		},
+		Annotations: map[string]string{protectedAnnotation: "true"},
+	}}))
+
+	resync := make(chan event.GenericEvent, 10)
+	configMapResync := make(chan event.GenericEvent, 10)
+	registrationResync := make(chan event.GenericEvent, 10)
+	cr := &ConfigReconciler{
+		Client:             c,
+		Log:                TestLog,
+		ConfigMap:          client.ObjectKeyFromObject(cm),
+		Resync:             resync,
+		ConfigMapResync:    configMapResync,
+		RegistrationResync: registrationResync,
+	}
+	result, err := cr.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.Equal(t, "argocd-new", CurrentConfig().ArgoNamespace)
+
+	// Every source is re-queued, and ArgoSecrets in the previous namespace are kept until
+	// they are replaced.
+	assert.Equal(t, "test-kubeconfig", (<-resync).Object.GetName())
+	assert.Equal(t, "cm-kubeconfig", (<-configMapResync).Object.GetName())
+	assert.Equal(t, "reg", (<-registrationResync).Object.GetName())
+	assert.Equal(t, movedRequeueAfter, result.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), oldKey, &corev1.Secret{}))
+
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	newKey := types.NamespacedName{Name: "cluster-test", Namespace: "argocd-new"}
+	assert.Nil(t, c.Get(context.Background(), newKey, &corev1.Secret{}))
+	assert.Nil(t, c.Create(context.Background(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster-reg",
+		Namespace: "argocd-new",
+		Labels: map[string]string{
+			"capi-to-argocd/owned":             "true",
+			clusterRegistrationNameLabel:       "reg",
+			"capi-to-argocd/cluster-namespace": TestNamespace,
+		},
+	}}))
+
+	// Not in dry-run mode though.
+	cr.DryRun = true
+	result, err = cr.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), oldKey, &corev1.Secret{}))
+
+	// Once replaced, they are pruned, but for protected ones.
+	cr.DryRun = false
+	cr.movedFrom = map[string]bool{oldConf.ArgoNamespace: true}
+	result, err = cr.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), oldKey, &corev1.Secret{})))
+	assert.Nil(t, c.Get(context.Background(), protectedKey, &corev1.Secret{}))
+
+	// Applying the same ConfigMap again is a no-op.
+	_, err = cr.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.Len(t, resync, 0)
+}
+
+func TestConfigReconcilerResyncDoesNotBlock(t *testing.T) {
+	oldConf := CurrentConfig()
+	defer ApplyConfig(oldConf)
+
+	cm := MockConfigMap(map[string]string{"ENABLE_NAMESPACED_NAMES": strconv.FormatBool(!oldConf.EnableNamespacedNames)})
+	_, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), cm)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nothing consumes the resync channel yet, eg. before its controller started.
+	resync := make(chan event.GenericEvent)
+	cr := &ConfigReconciler{Client: c, Log: TestLog, ConfigMap: client.ObjectKeyFromObject(cm), Resync: resync}
+	_, err := cr.Reconcile(ctx, MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.Equal(t, "test-kubeconfig", (<-resync).Object.GetName())
+}
+
+func TestRecordFeatures(t *testing.T) {
+	oldConf := CurrentConfig()
+	defer ApplyConfig(oldConf)
+
+	RecordFeatures(Config{EnableGarbageCollection: true})
+	assert.Equal(t, 1.0, MockGaugeValue(featureEnabled.WithLabelValues("gc")))
+	assert.Equal(t, 0.0, MockGaugeValue(featureEnabled.WithLabelValues("namespaced_names")))
+
+	// Startup toggles are reported along.
+	startup := map[string]*bool{
+		"dry_run":                     &EnableDryRun,
+		"watch_configmaps":            &WatchConfigMaps,
+		"cluster_registrations":       &EnableClusterRegistrations,
+		"gc_on_startup":               &GCOnStartup,
+		"self_registration":           &AllowSelfRegistration,
+		"server_templates":            &AllowServerTemplates,
+		"register_all_contexts":       &RegisterAllContexts,
+		"allow_empty_users":           &AllowEmptyUsers,
+		"reject_loopback_servers":     &RejectLoopbackServers,
+		"skip_duplicate_servers":      &SkipDuplicateServers,
+		"wait_for_ready_condition":    &WaitForReadyCondition,
+		"omit_bearer_token":           &OmitBearerToken,
+		"sanitize_names":              &SanitizeNames,
+		"compress_config":             &EnableCompressConfig,
+		"force_ca_configmap":          &ForceCABundle,
+		"warn_empty_takealong_values": &WarnEmptyTakeAlongValues,
+		"takeover_secret_type":        &TakeoverSecretType,
+		"allow_recreate":              &AllowRecreate,
+		"exemplars":                   &EnableExemplars,
+	}
+	assert.Len(t, Config{}.Features(), len(startup)+2)
+	for feature, toggle := range startup {
+		oldToggle := *toggle
+		for _, enabled := range []bool{true, false} {
+			*toggle = enabled
+			RecordFeatures(CurrentConfig())
+			assert.Equal(t, map[bool]float64{true: 1, false: 0}[enabled], MockGaugeValue(featureEnabled.WithLabelValues(feature)), feature)
+		}
+		*toggle = oldToggle
+	}
+
+	// Runtime configuration changes are reflected.
+	ApplyConfig(Config{ArgoNamespace: oldConf.ArgoNamespace, EnableNamespacedNames: true})
+	assert.Equal(t, 0.0, MockGaugeValue(featureEnabled.WithLabelValues("gc")))
+	assert.Equal(t, 1.0, MockGaugeValue(featureEnabled.WithLabelValues("namespaced_names")))
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// KubeConfigMapReconciler reconciles ConfigMaps holding non-sensitive kubeconfigs
+// (eg. token-less, with a public CA) into ArgoSecrets. ConfigMaps follow the same
+// <clusterName>-kubeconfig naming and KubeConfigDataKeys conventions as CAPI secrets.
+type KubeConfigMapReconciler struct {
+	Capi2Argo
+}
+
+// Reconcile converts a kubeconfig ConfigMap into an ArgoSecret.
+func (r *KubeConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("configmap", req.NamespacedName)
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	if paused(ctx, r.Client, log) {
+		log.Info("Reconciles are paused, requeueing...", "namespace", OperatorNamespace, "after", PausedRequeueAfter)
+		return ctrl.Result{RequeueAfter: PausedRequeueAfter}, nil
+	}
+
+	if !ValidateWatchNamespace(req.Namespace) || !ValidateCapiNaming(req.NamespacedName) {
+		return ctrl.Result{}, nil
+	}
+
+	var cm corev1.ConfigMap
+	if err := r.Get(ctx, req.NamespacedName, &cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		forgetTakeAlong(clusterOfSource(req.NamespacedName))
+		if !EnableGarbageCollection {
+			return ctrl.Result{}, nil
+		}
+		if wait := r.gcRecheckAfter(req.NamespacedName); wait > 0 {
+			log.Info("KubeConfig ConfigMap deleted, rechecking before garbage collection", "after", wait.String())
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		return ctrl.Result{}, r.garbageCollect(ctx, log, req.NamespacedName, &cm)
+	}
+	if r.forgetGCPending(req.NamespacedName) {
+		log.Info("KubeConfig ConfigMap reappeared, skipping garbage collection")
+	}
+	log.Info("Fetched KubeConfig ConfigMap")
+
+	// Present the ConfigMap as a CAPI secret, so it goes through the same pipeline.
+	capiSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cm.Name,
+			Namespace:   cm.Namespace,
+			Labels:      cm.Labels,
+			Annotations: cm.Annotations,
+		},
+		Type: CapiClusterSecretType,
+		Data: ConfigMapData(&cm),
+	}
+	return r.sync(ctx, log, &cm, capiSecret)
+}
+
+// SetupWithManager registers the ConfigMap watch.
+func (r *KubeConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.owners = &sync.Map{}
+	r.gcPending = &sync.Map{}
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("kubeconfigmap").
+		For(&corev1.ConfigMap{}).
+		WithOptions(controller.Options{RateLimiter: newRateLimiter()})
+	if r.Resync != nil {
+		b = b.WatchesRawSource(source.Channel(r.Resync, &handler.EnqueueRequestForObject{}))
+	}
+	return b.Complete(r)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func MockKubeConfigMap(name string, namespace string) *corev1.ConfigMap {
+	v, _ := b64.StdEncoding.DecodeString(MockCapiKubeConfig())
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string]string{"value": string(v)},
+	}
+}
+
+func TestReconcileKubeConfigMap(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	EnableGarbageCollection = true
+	defer func() { EnableGarbageCollection = oldConf }()
+
+	cm := MockKubeConfigMap("test-kubeconfig", TestNamespace)
+	c2a, c := MockReconciler(cm)
+	r := &KubeConfigMapReconciler{Capi2Argo: *c2a}
+	req := MockReconcileReq(cm.Name, cm.Namespace)
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Equal(t, "test-kubeconfig", argoSecret.Labels["capi-to-argocd/cluster-secret-name"])
+	assert.Equal(t, "https://kube-cluster-test.domain.com:6443", string(argoSecret.Data["server"]))
+
+	// Deleting the ConfigMap garbage collects its ArgoSecret.
+	assert.Nil(t, c.Delete(context.Background(), cm))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestReconcileKubeConfigMapGCRecheck(t *testing.T) {
+	oldConf, oldDelay := EnableGarbageCollection, GCRecheckDelay
+	EnableGarbageCollection, GCRecheckDelay = true, time.Hour
+	defer func() { EnableGarbageCollection, GCRecheckDelay = oldConf, oldDelay }()
+
+	cm := MockKubeConfigMap("test-kubeconfig", TestNamespace)
+	c2a, c := MockReconciler(cm)
+	r := &KubeConfigMapReconciler{Capi2Argo: *c2a}
+	r.gcPending = &sync.Map{}
+	req := MockReconcileReq(cm.Name, cm.Namespace)
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+
+	// A ConfigMap recreated before the recheck keeps its ArgoSecret.
+	assert.Nil(t, c.Delete(context.Background(), cm))
+	res, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Greater(t, res.RequeueAfter, time.Duration(0))
+	assert.Nil(t, c.Create(context.Background(), cm.DeepCopy()))
+	res, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcileKubeConfigMapServerCAOnly(t *testing.T) {
+	oldSource, oldSecret := ConfigSource, CredentialsSecret
+	ConfigSource = ConfigSourceServerCAOnly
+	CredentialsSecret = types.NamespacedName{Name: "argocd-sa", Namespace: ArgoNamespace}
+	defer func() { ConfigSource, CredentialsSecret = oldSource, oldSecret }()
+
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: CredentialsSecret.Name, Namespace: CredentialsSecret.Namespace},
+		Data:       map[string][]byte{"bearerToken": []byte("shared")},
+	}
+	cm := MockKubeConfigMap("test-kubeconfig", TestNamespace)
+	c2a, c := MockReconciler(cm, credentials)
+	r := &KubeConfigMapReconciler{Capi2Argo: *c2a}
+
+	// ConfigMaps are never given the out-of-band credentials.
+	_, err := r.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+}
+
+func TestReconcileKubeConfigMapInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		testName          string
+		testMock          *corev1.ConfigMap
+		testExpectedError bool
+	}{
+		{"test non-kubeconfig name", MockKubeConfigMap("test-config", TestNamespace), false},
+		{"test missing value key", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-kubeconfig", Namespace: TestNamespace}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			c2a, c := MockReconciler(tt.testMock)
+			r := &KubeConfigMapReconciler{Capi2Argo: *c2a}
+			_, err := r.Reconcile(context.Background(), MockReconcileReq(tt.testMock.Name, tt.testMock.Namespace))
+			if tt.testExpectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			list := &corev1.SecretList{}
+			assert.Nil(t, c.List(context.Background(), list))
+			assert.Len(t, list.Items, 0)
+		})
+	}
+}
@@ -1,12 +1,36 @@
 package controllers
 
 import (
+	"context"
 	b64 "encoding/base64"
 	"log"
 	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // MockCapiKubeConfig returns a based64-encoded string that
@@ -68,6 +92,15 @@ func MockCapiSecret(validMock bool, validType bool, validKey bool, name string,
 	return &s
 }
 
+// MockCapiCluster returns a CapiCluster unmarshalled from a valid CAPI secret.
+func MockCapiCluster(name string, namespace string) *CapiCluster {
+	c := NewCapiCluster(name, namespace)
+	if err := c.Unmarshal(MockCapiSecret(true, true, true, name+"-kubeconfig", namespace)); err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
 func MockArgoCluster(validMock bool) *ArgoCluster {
 	// If validMock=true, return type with proper b64 encoded values
 	var v string
@@ -78,7 +111,7 @@ func MockArgoCluster(validMock bool) *ArgoCluster {
 	}
 
 	a := &ArgoCluster{
-		NamespacedName: BuildNamespacedName("test", "test"),
+		NamespacedName: BuildNamespacedName("test", "test", nil),
 		ClusterName:    "test",
 		ClusterServer:  "server",
 		ClusterLabels: map[string]string{
@@ -109,3 +142,319 @@ func IsBase64(s string) bool {
 	_, err := b64.StdEncoding.DecodeString(s)
 	return err == nil
 }
+
+// MockCluster returns a CAPI Cluster object carrying given labels and annotations.
+func MockCluster(name string, namespace string, labels map[string]string, annotations map[string]string) *clusterv1.Cluster {
+	return &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+}
+
+// MockCounterValue returns the current value of a prometheus counter.
+func MockCounterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		log.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// MockGaugeValue returns the current value of a prometheus gauge.
+func MockGaugeValue(g prometheus.Gauge) float64 {
+	m := &dto.Metric{}
+	if err := g.Write(m); err != nil {
+		log.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func MockHistogramCount(h prometheus.Histogram) uint64 {
+	m := &dto.Metric{}
+	if err := h.Write(m); err != nil {
+		log.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+// MockLogSink records the messages logged up to Verbosity.
+type MockLogSink struct {
+	Verbosity int
+	mu        *sync.Mutex
+	messages  *[]string
+}
+
+// NewMockLogger returns a logger backed by a MockLogSink of given verbosity.
+func NewMockLogger(verbosity int) (logr.Logger, *MockLogSink) {
+	s := &MockLogSink{Verbosity: verbosity, mu: &sync.Mutex{}, messages: &[]string{}}
+	return logr.New(s), s
+}
+
+// Messages returns the recorded messages.
+func (s *MockLogSink) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(*s.messages)
+}
+
+func (s *MockLogSink) Init(logr.RuntimeInfo) {}
+
+func (s *MockLogSink) Enabled(level int) bool { return level <= s.Verbosity }
+
+func (s *MockLogSink) Info(_ int, msg string, _ ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.messages = append(*s.messages, msg)
+}
+
+func (s *MockLogSink) Error(_ error, msg string, _ ...any) { s.Info(0, msg) }
+
+func (s *MockLogSink) WithValues(...any) logr.LogSink { return s }
+
+func (s *MockLogSink) WithName(string) logr.LogSink { return s }
+
+// MockReconciler returns a Capi2Argo reconciler backed by a MockClient holding given objects.
+func MockReconciler(objs ...client.Object) (*Capi2Argo, *MockClient) {
+	c := NewMockClient(objs...)
+	return &Capi2Argo{
+		Client:   c,
+		Log:      TestLog,
+		Scheme:   scheme.Scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}, c
+}
+
+type mockKey struct {
+	kind string
+	nn   types.NamespacedName
+}
+
+// MockClient is a minimal in-memory client.Client used to exercise Reconcile
+// without a running API server. Only the calls the controller makes are implemented.
+type MockClient struct {
+	client.Client
+	mu      sync.Mutex
+	objects map[mockKey]client.Object
+	version int
+	// managers holds the field manager of the last write of each object.
+	managers map[mockKey]string
+
+	// OnGet optionally runs before Get calls, failing them with the returned error.
+	// It runs unlocked, so it may use the client (eg. to simulate a concurrent writer).
+	OnGet func(key client.ObjectKey, obj client.Object) error
+	// OnUpdate optionally intercepts Update calls, failing them with the returned error.
+	OnUpdate func(obj client.Object) error
+	// OnCreate optionally runs before Create calls, failing them with the returned error.
+	// It runs unlocked, so it may use the client (eg. to simulate a concurrent writer).
+	OnCreate func(obj client.Object) error
+	// OnPatch optionally intercepts Patch calls, failing them with the returned error.
+	OnPatch func(obj client.Object, patch client.Patch) error
+	// OnDelete optionally intercepts Delete calls, failing them with the returned error.
+	OnDelete func(obj client.Object) error
+	// Indexers optionally index objects by field, for List calls with field selectors.
+	Indexers map[string]client.IndexerFunc
+}
+
+// NewMockClient returns a MockClient pre-populated with given objects.
+func NewMockClient(objs ...client.Object) *MockClient {
+	c := &MockClient{objects: map[mockKey]client.Object{}, managers: map[mockKey]string{}}
+	for _, o := range objs {
+		if err := c.Create(context.Background(), o); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return c
+}
+
+func mockKind(obj runtime.Object) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	return reflect.TypeOf(obj).Elem().Name()
+}
+
+func mockNotFound(kind string, name string) error {
+	return apierrors.NewNotFound(schema.GroupResource{Resource: strings.ToLower(kind) + "s"}, name)
+}
+
+// FieldManager returns the field manager of the last write of obj.
+func (c *MockClient) FieldManager(obj client.Object) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.managers[mockKey{mockKind(obj), client.ObjectKeyFromObject(obj)}]
+}
+
+func (c *MockClient) nextVersion() string {
+	c.version++
+	return strconv.Itoa(c.version)
+}
+
+// Get implements client.Client.
+func (c *MockClient) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	if c.OnGet != nil {
+		if err := c.OnGet(key, obj); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored, ok := c.objects[mockKey{mockKind(obj), key}]
+	if !ok {
+		return mockNotFound(mockKind(obj), key.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored.DeepCopyObject()).Elem())
+	return nil
+}
+
+// List implements client.Client.
+func (c *MockClient) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o := &client.ListOptions{}
+	o.ApplyOptions(opts)
+	kind := strings.TrimSuffix(mockKind(list), "List")
+	items := []runtime.Object{}
+	for k, stored := range c.objects {
+		if k.kind != kind {
+			continue
+		}
+		if o.Namespace != "" && o.Namespace != k.nn.Namespace {
+			continue
+		}
+		if o.LabelSelector != nil && !o.LabelSelector.Matches(labels.Set(stored.GetLabels())) {
+			continue
+		}
+		if o.FieldSelector != nil && !c.matchesFields(stored, o.FieldSelector) {
+			continue
+		}
+		items = append(items, stored.DeepCopyObject())
+	}
+	return meta.SetList(list, items)
+}
+
+// matchesFields returns true when the Indexers of c index o under every field of selector.
+func (c *MockClient) matchesFields(o client.Object, selector fields.Selector) bool {
+	for _, r := range selector.Requirements() {
+		indexer, ok := c.Indexers[r.Field]
+		if !ok || !slices.Contains(indexer(o), r.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create implements client.Client.
+func (c *MockClient) Create(_ context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.OnCreate != nil {
+		if err := c.OnCreate(obj); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := mockKey{mockKind(obj), client.ObjectKeyFromObject(obj)}
+	if _, ok := c.objects[k]; ok {
+		return apierrors.NewAlreadyExists(schema.GroupResource{Resource: strings.ToLower(k.kind) + "s"}, k.nn.Name)
+	}
+	// Like the API server, tell apart objects recreated under the same name.
+	if obj.GetUID() == "" {
+		obj.SetUID(uuid.NewUUID())
+	}
+	obj.SetResourceVersion(c.nextVersion())
+	c.objects[k] = obj.DeepCopyObject().(client.Object)
+	c.managers[k] = (&client.CreateOptions{}).ApplyOptions(opts).FieldManager
+	return nil
+}
+
+// Update implements client.Client.
+func (c *MockClient) Update(_ context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.OnUpdate != nil {
+		if err := c.OnUpdate(obj); err != nil {
+			return err
+		}
+	}
+	k := mockKey{mockKind(obj), client.ObjectKeyFromObject(obj)}
+	stored, ok := c.objects[k]
+	if !ok {
+		return mockNotFound(k.kind, k.nn.Name)
+	}
+	if obj.GetResourceVersion() != "" && obj.GetResourceVersion() != stored.GetResourceVersion() {
+		return apierrors.NewConflict(schema.GroupResource{Resource: strings.ToLower(k.kind) + "s"}, k.nn.Name, nil)
+	}
+	obj.SetResourceVersion(c.nextVersion())
+	c.objects[k] = obj.DeepCopyObject().(client.Object)
+	c.managers[k] = (&client.UpdateOptions{}).ApplyOptions(opts).FieldManager
+	return nil
+}
+
+// Patch implements client.Client. The patch itself is ignored and obj is stored as-is,
+// which matches the outcome of the merge patches the controller sends.
+func (c *MockClient) Patch(_ context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.OnPatch != nil {
+		if err := c.OnPatch(obj, patch); err != nil {
+			return err
+		}
+	}
+	k := mockKey{mockKind(obj), client.ObjectKeyFromObject(obj)}
+	if _, ok := c.objects[k]; !ok {
+		return mockNotFound(k.kind, k.nn.Name)
+	}
+	obj.SetResourceVersion(c.nextVersion())
+	c.objects[k] = obj.DeepCopyObject().(client.Object)
+	c.managers[k] = (&client.PatchOptions{}).ApplyOptions(opts).FieldManager
+	return nil
+}
+
+// Delete implements client.Client.
+func (c *MockClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.OnDelete != nil {
+		if err := c.OnDelete(obj); err != nil {
+			return err
+		}
+	}
+	k := mockKey{mockKind(obj), client.ObjectKeyFromObject(obj)}
+	if _, ok := c.objects[k]; !ok {
+		return mockNotFound(k.kind, k.nn.Name)
+	}
+	delete(c.objects, k)
+	delete(c.managers, k)
+	return nil
+}
+
+// resourceVersions returns the resourceVersion of every stored object, keyed by kind and
+// namespaced name.
+func (c *MockClient) resourceVersions() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	versions := map[string]string{}
+	for k, o := range c.objects {
+		versions[k.kind+"/"+k.nn.String()] = o.GetResourceVersion()
+	}
+	return versions
+}
+
+// AssertReconcileIdempotent reconciles req twice with r, and asserts that the second pass
+// writes nothing, ie. that the resourceVersion of every object of c is stable.
+func AssertReconcileIdempotent(t assert.TestingT, c *MockClient, r reconcile.Reconciler, req reconcile.Request) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if _, err := r.Reconcile(context.Background(), req); !assert.Nil(t, err) {
+		return false
+	}
+	before := c.resourceVersions()
+	if _, err := r.Reconcile(context.Background(), req); !assert.Nil(t, err) {
+		return false
+	}
+	return assert.Equal(t, before, c.resourceVersions(), "reconciling %s again is not a no-op", req.NamespacedName)
+}
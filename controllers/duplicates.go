@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SkipDuplicateServers skips registering clusters whose server is already registered by an
+// ArgoSecret of another source. ArgoSecrets registered before the duplicate appeared are
+// kept in-sync.
+var SkipDuplicateServers bool
+
+// findDuplicateServer returns an owned ArgoSecret generated from a source other than nn,
+// registering the same server as argoCluster in its namespace, if any. registered reports
+// whether the ArgoSecret of argoCluster already exists.
+func (r *Capi2Argo) findDuplicateServer(ctx context.Context, nn types.NamespacedName, argoCluster *ArgoCluster) (duplicate *corev1.Secret, registered bool, err error) {
+	secretList := &corev1.SecretList{}
+	if err := r.List(ctx, secretList, client.InNamespace(argoCluster.NamespacedName.Namespace),
+		client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+		return nil, false, err
+	}
+	for i := range secretList.Items {
+		s := &secretList.Items[i]
+		if s.Name == argoCluster.NamespacedName.Name {
+			registered = true
+			continue
+		}
+		// Contexts of the same kubeconfig may share a server.
+		if s.Labels["capi-to-argocd/cluster-secret-name"] == nn.Name && s.Labels["capi-to-argocd/cluster-namespace"] == nn.Namespace {
+			continue
+		}
+		if duplicate == nil && sameServer(string(s.Data[ArgoServerKey]), argoCluster.ClusterServer) {
+			duplicate = s
+		}
+	}
+	return duplicate, registered, nil
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EnableExemplars attaches the trace ID of the reconcile, when traced (see --otel-endpoint),
+// as an exemplar to the reconcile duration histogram, so that metrics link back to traces.
+var EnableExemplars bool
+
+// traceIDFromContext returns the trace ID of the sampled span of ctx, eg. the span of the
+// reconcile, or an empty string.
+func traceIDFromContext(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// observeReconcileDuration records the duration of a reconcile, with the trace ID of ctx as
+// an exemplar when EnableExemplars is set.
+func observeReconcileDuration(ctx context.Context, d time.Duration) {
+	traceID := traceIDFromContext(ctx)
+	if !EnableExemplars || traceID == "" {
+		reconcileDurationSeconds.Observe(d.Seconds())
+		return
+	}
+	reconcileDurationSeconds.(prometheus.ExemplarObserver).ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+}
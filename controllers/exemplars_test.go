@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// mockExemplars returns the trace IDs of the exemplars of reconcileDurationSeconds.
+func mockExemplars(t *testing.T) []string {
+	m := &dto.Metric{}
+	assert.Nil(t, reconcileDurationSeconds.Write(m))
+	var traceIDs []string
+	for _, b := range m.GetHistogram().GetBucket() {
+		for _, l := range b.GetExemplar().GetLabel() {
+			if l.GetName() == "trace_id" {
+				traceIDs = append(traceIDs, l.GetValue())
+			}
+		}
+	}
+	return traceIDs
+}
+
+func TestObserveReconcileDurationExemplars(t *testing.T) {
+	oldConf := EnableExemplars
+	defer func() { EnableExemplars = oldConf }()
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	traceID := span.SpanContext().TraceID().String()
+	d := 42 * time.Second
+
+	// Neither without a trace context, nor when disabled.
+	EnableExemplars = true
+	observeReconcileDuration(context.Background(), d)
+	EnableExemplars = false
+	observeReconcileDuration(ctx, d)
+	assert.NotContains(t, mockExemplars(t), traceID)
+
+	EnableExemplars = true
+	observeReconcileDuration(ctx, d)
+	assert.Contains(t, mockExemplars(t), traceID)
+}
+
+func TestReconcileExemplars(t *testing.T) {
+	oldConf := EnableExemplars
+	defer func() { EnableExemplars = oldConf }()
+	EnableExemplars = true
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	r, _ := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+
+	// The reconcile span is the one linked from the metric.
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, mockExemplars(t), spans[0].SpanContext.TraceID().String())
+}
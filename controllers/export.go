@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// redactedValue replaces the config of exported ArgoSecrets when redacting.
+const redactedValue = "REDACTED"
+
+// unredactedKeys are the data keys, besides ArgoNameKey and ArgoServerKey, holding no
+// credentials and exported as-is when redacting.
+var unredactedKeys = []string{"project", "namespaces", "clusterResources"}
+
+// ExportArgoSecrets writes the ArgoSecrets owned by CACO in namespace (all namespaces if
+// empty) to w as a YAML stream, eg. to commit them to a repository. Server-populated
+// metadata is stripped, and with redact the config holding credentials is replaced, along
+// with any other data but the name, server and unredactedKeys, so that credentials stored
+// under an unexpected key (eg. a custom ArgoConfigKey) never leak.
+func ExportArgoSecrets(ctx context.Context, c client.Reader, w io.Writer, namespace string, redact bool) error {
+	secretList := &corev1.SecretList{}
+	if err := c.List(ctx, secretList, client.InNamespace(namespace), client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+		return err
+	}
+	sort.Slice(secretList.Items, func(i, j int) bool {
+		a, b := secretList.Items[i], secretList.Items[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	for i := range secretList.Items {
+		s := exportSecret(&secretList.Items[i], redact)
+		out, err := yaml.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportSecret returns a copy of s fit for applying elsewhere.
+func exportSecret(s *corev1.Secret, redact bool) *corev1.Secret {
+	out := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        s.Name,
+			Namespace:   s.Namespace,
+			Labels:      s.Labels,
+			Annotations: s.Annotations,
+		},
+		Type: s.Type,
+		Data: map[string][]byte{},
+	}
+	for k, v := range s.Data {
+		if redact && k != ArgoNameKey && k != ArgoServerKey && !slices.Contains(unredactedKeys, k) {
+			v = []byte(redactedValue)
+		}
+		out.Data[k] = v
+	}
+	return out
+}
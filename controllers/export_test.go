@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestExportArgoSecrets(t *testing.T) {
+	t.Parallel()
+	b := MockArgoSecret()
+	b.Name = "cluster-b"
+	a := MockArgoSecret()
+	a.Name = "cluster-a"
+	unowned := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: a.Namespace}}
+	c := NewMockClient(b, a, unowned)
+
+	for _, redact := range []bool{false, true} {
+		var out bytes.Buffer
+		assert.Nil(t, ExportArgoSecrets(context.Background(), c, &out, a.Namespace, redact))
+
+		docs := strings.Split(strings.TrimPrefix(out.String(), "---\n"), "---\n")
+		if !assert.Len(t, docs, 2) {
+			continue
+		}
+		for i, name := range []string{"cluster-a", "cluster-b"} {
+			var s corev1.Secret
+			assert.Nil(t, yaml.Unmarshal([]byte(docs[i]), &s))
+			assert.Equal(t, "Secret", s.Kind)
+			assert.Equal(t, name, s.Name)
+			assert.Equal(t, a.Namespace, s.Namespace)
+			assert.Empty(t, s.ResourceVersion)
+			assert.Equal(t, "cluster", s.Labels["argocd.argoproj.io/secret-type"])
+			assert.Equal(t, a.Data["server"], s.Data["server"])
+			assert.Equal(t, a.Data["name"], s.Data["name"])
+			if redact {
+				assert.Equal(t, redactedValue, string(s.Data["config"]))
+			} else {
+				assert.Equal(t, a.Data["config"], s.Data["config"])
+			}
+		}
+	}
+}
+
+func TestExportSecretRedactsUnknownKeys(t *testing.T) {
+	s := MockArgoSecret()
+	s.Data["project"] = []byte("team-a")
+	s.Data["cfg"] = []byte(`{"bearerToken":"secret"}`)
+
+	out := exportSecret(s, true)
+	assert.Equal(t, redactedValue, string(out.Data["config"]))
+	assert.Equal(t, redactedValue, string(out.Data["cfg"]))
+	assert.Equal(t, "team-a", string(out.Data["project"]))
+	assert.Equal(t, s.Data["name"], out.Data["name"])
+	assert.Equal(t, s.Data["server"], out.Data["server"])
+}
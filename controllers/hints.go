@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// rememberEcho records rv as the resourceVersion of a CAPI secret right after CACO itself
+// updated it (eg. its sync status) at the end of a successful reconcile.
+func (r *Capi2Argo) rememberEcho(nn types.NamespacedName, rv string) {
+	if r.echoes != nil {
+		r.echoes.Store(nn, rv)
+	}
+}
+
+// consumeEcho returns true, once, when rv is the resourceVersion remembered for nn, ie.
+// when the event being reconciled is the echo of CACO's own update.
+func (r *Capi2Argo) consumeEcho(nn types.NamespacedName, rv string) bool {
+	return r.echoes != nil && r.echoes.CompareAndDelete(nn, rv)
+}
+
+// forgetEchoes wraps mapFn so that the mapped requests are never skipped as echoes, eg.
+// for Cluster changes, which do not show in the resourceVersion of the CAPI secret.
+func (r *Capi2Argo) forgetEchoes(mapFn handler.MapFunc) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		requests := mapFn(ctx, o)
+		for _, req := range requests {
+			if r.echoes != nil {
+				r.echoes.Delete(req.NamespacedName)
+			}
+		}
+		return requests
+	}
+}
+
+// objectToRequest maps an object to the request of itself.
+func objectToRequest(_ context.Context, o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(o)}}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileSkipsEcho(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	cluster := MockCluster("test", TestNamespace, nil, nil)
+	r, c := MockReconciler(capiSecret, cluster)
+	r.echoes = &sync.Map{}
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	argoKey := types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}
+
+	// The first reconcile records its status on the CapiSecret, whose echo is skipped.
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	argoSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+	assert.Nil(t, c.Delete(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), argoKey, &corev1.Secret{})))
+
+	// The echo is skipped only once.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, argoSecret))
+
+	// Cluster events are never skipped, even when coalesced with an echo.
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	r.rememberEcho(req.NamespacedName, capiSecret.ResourceVersion)
+	assert.Equal(t, []reconcile.Request{req}, r.forgetEchoes(clusterToCapiSecret)(context.Background(), cluster))
+	assert.Nil(t, c.Delete(context.Background(), argoSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Get(context.Background(), argoKey, &corev1.Secret{}))
+}
+
+func TestReconcileRemembersNoEchoOnError(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	r.echoes = &sync.Map{}
+
+	// Failed reconciles are retried in full.
+	r.updateSyncStatus(context.Background(), capiSecret, apierrors.NewBadRequest("test"))
+	assert.False(t, r.consumeEcho(client.ObjectKeyFromObject(capiSecret), capiSecret.ResourceVersion))
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(capiSecret), capiSecret))
+	r.updateSyncStatus(context.Background(), capiSecret, nil)
+	assert.True(t, r.consumeEcho(client.ObjectKeyFromObject(capiSecret), capiSecret.ResourceVersion))
+	assert.False(t, r.consumeEcho(client.ObjectKeyFromObject(capiSecret), capiSecret.ResourceVersion))
+}
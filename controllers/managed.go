@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ManagedSecretsReporter exports the number of ArgoSecrets managed by CACO as
+// caco_argocd_secrets_managed every Interval. Counting them is a List, so it is not done on
+// every reconcile.
+type ManagedSecretsReporter struct {
+	Client   client.Client
+	Interval time.Duration
+	Log      logr.Logger
+}
+
+// Report counts the ArgoSecrets owned by CACO, shadow copies aside. The gauge is set from
+// the listing alone and never moved by reconciles, so concurrent reconciles cannot skew it.
+func (m *ManagedSecretsReporter) Report(ctx context.Context) error {
+	secretList := &corev1.SecretList{}
+	if err := m.Client.List(ctx, secretList, client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+		return err
+	}
+	n := 0
+	for i := range secretList.Items {
+		if !isShadowSecret(&secretList.Items[i]) {
+			n++
+		}
+	}
+	managedSecrets.Set(float64(n))
+	return nil
+}
+
+// Start implements manager.Runnable, reporting the managed ArgoSecrets every Interval until
+// ctx is done.
+func (m *ManagedSecretsReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := m.Report(ctx); err != nil {
+				m.Log.Error(err, "Failed to report managed ArgoSecrets")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader reports,
+// so that replicas do not export the same ArgoSecrets twice.
+func (m *ManagedSecretsReporter) NeedLeaderElection() bool {
+	return true
+}
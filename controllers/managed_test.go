@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestManagedSecretsReporter(t *testing.T) {
+	var objs []client.Object
+	for _, name := range []string{"a", "b"} {
+		s := MockCapiSecret(validMock, validType, validKey, name+"-kubeconfig", TestNamespace)
+		s.Labels[clusterv1.ClusterNameLabel] = name
+		objs = append(objs, s)
+	}
+	// Secrets not owned by CACO are not counted.
+	objs = append(objs, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "foreign",
+		Namespace: ArgoNamespace,
+		Labels:    map[string]string{"argocd.argoproj.io/secret-type": "cluster"},
+	}})
+	r, c := MockReconciler(objs...)
+	for _, name := range []string{"a", "b"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name+"-kubeconfig", TestNamespace))
+		assert.Nil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := &ManagedSecretsReporter{Client: c, Interval: 10 * time.Millisecond, Log: TestLog}
+	go func() { _ = m.Start(ctx) }()
+	assert.Eventually(t, func() bool { return MockGaugeValue(managedSecrets) == 2 }, time.Second, 10*time.Millisecond)
+
+	// The next tick follows deletions.
+	secretList := &corev1.SecretList{}
+	assert.Nil(t, c.List(context.Background(), secretList, client.MatchingLabels{"capi-to-argocd/owned": "true"}))
+	assert.Nil(t, c.Delete(context.Background(), &secretList.Items[0]))
+	assert.Eventually(t, func() bool { return MockGaugeValue(managedSecrets) == 1 }, time.Second, 10*time.Millisecond)
+}
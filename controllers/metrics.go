@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	secretsCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_argocd_secrets_created_total",
+		Help: "Number of ArgoSecrets created.",
+	})
+
+	secretsUpdatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_argocd_secrets_updated_total",
+		Help: "Number of out-of-sync ArgoSecrets updated.",
+	})
+
+	secretsDeletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_argocd_secrets_deleted_total",
+		Help: "Number of ArgoSecrets deleted.",
+	})
+
+	argoSecretNameCollisionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_argocd_secret_name_collisions_total",
+		Help: "Number of CAPI secrets rejected because their ArgoSecret name is taken by another CAPI secret.",
+	})
+
+	argoSecretNameTooLongTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_argocd_secret_name_too_long_total",
+		Help: "Number of CAPI secrets rejected because their ArgoSecret name exceeds the Kubernetes name limit.",
+	})
+
+	duplicateServersTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_duplicate_servers_total",
+		Help: "Number of reconciles of clusters whose server is already registered by another ArgoSecret.",
+	})
+
+	loopbackServersTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_loopback_servers_total",
+		Help: "Number of reconciles of clusters skipped because their server is a loopback address.",
+	})
+
+	tokenRotationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_token_rotations_total",
+		Help: "Number of ArgoSecret updates where only the bearer token changed.",
+	})
+
+	caRotationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_ca_rotations_total",
+		Help: "Number of ArgoSecret updates where the CA data changed.",
+	})
+
+	takeAlongLabelsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_takealong_labels_dropped_total",
+		Help: "Number of take-along labels dropped for exceeding the take-along label limit.",
+	})
+
+	takeAlongEmptyValuesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "caco_takealong_empty_values_total",
+		Help: "Number of take-along labels found with an empty value, with --warn-empty-takealong-values.",
+	})
+
+	kubeConfigCertExpirySeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "caco_kubeconfig_cert_expiry_seconds",
+		Help: "Expiry of kubeconfig client certificates, as a Unix timestamp in seconds.",
+	}, []string{"cluster"})
+
+	featureEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "caco_feature_enabled",
+		Help: "Whether a feature of CACO is enabled (1) or not (0).",
+	}, []string{"feature"})
+
+	managedSecrets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "caco_argocd_secrets_managed",
+		Help: "Number of ArgoSecrets managed by CACO, as of the last refresh.",
+	})
+
+	clustersByPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "caco_clusters_by_phase",
+		Help: "Number of clusters registered in ArgoCD, by the phase of their CAPI Cluster.",
+	}, []string{"phase"})
+
+	reconcileDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "caco_reconcile_duration_seconds",
+		Help:    "Time spent reconciling CAPI secrets, with trace ID exemplars when enabled.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	kubeConfigParseSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "caco_kubeconfig_parse_seconds",
+		Help:    "Time spent parsing kubeconfigs.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	})
+)
+
+func init() {
+	// Register custom metrics with the controller-runtime global registry,
+	// so they are exposed alongside the manager metrics. Controller-runtime
+	// registers the workqueue metrics (eg. workqueue_depth), labeled by
+	// controller name, on the same registry itself.
+	metrics.Registry.MustRegister(
+		secretsCreatedTotal,
+		secretsUpdatedTotal,
+		secretsDeletedTotal,
+		argoSecretNameCollisionsTotal,
+		argoSecretNameTooLongTotal,
+		duplicateServersTotal,
+		loopbackServersTotal,
+		tokenRotationsTotal,
+		caRotationsTotal,
+		takeAlongLabelsDroppedTotal,
+		takeAlongEmptyValuesTotal,
+		kubeConfigParseSeconds,
+		kubeConfigCertExpirySeconds,
+		reconcileDurationSeconds,
+		featureEnabled,
+		managedSecrets,
+		clustersByPhase,
+	)
+}
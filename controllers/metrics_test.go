@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestWorkQueueDepthMetric(t *testing.T) {
+	t.Parallel()
+	q := workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{Name: "caco-test"})
+	defer q.ShutDown()
+	q.Add("test")
+
+	families, err := metrics.Registry.Gather()
+	assert.Nil(t, err)
+	var depth float64 = -1
+	for _, f := range families {
+		if f.GetName() != "workqueue_depth" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "name" && l.GetValue() == "caco-test" {
+					depth = m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, float64(1), depth)
+}
+
+// gatherCounter scrapes the value of the counter name from the metrics registry.
+func gatherCounter(t *testing.T, name string) float64 {
+	families, err := metrics.Registry.Gather()
+	assert.Nil(t, err)
+	for _, f := range families {
+		if f.GetName() == name {
+			return f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("counter %s is not registered", name)
+	return 0
+}
+
+func TestArgoSecretsMetrics(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	r, c := MockReconciler(capiSecret)
+	req := MockReconcileReq("test-kubeconfig", TestNamespace)
+	created := gatherCounter(t, "caco_argocd_secrets_created_total")
+	updated := gatherCounter(t, "caco_argocd_secrets_updated_total")
+	deleted := gatherCounter(t, "caco_argocd_secrets_deleted_total")
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, created+1, gatherCounter(t, "caco_argocd_secrets_created_total"))
+
+	// In-sync ArgoSecrets are not updated.
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, updated, gatherCounter(t, "caco_argocd_secrets_updated_total"))
+
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, capiSecret))
+	capiSecret.Data["value"] = bytes.Replace(capiSecret.Data["value"], []byte("token: test"), []byte("token: rotated"), 1)
+	assert.Nil(t, c.Update(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, updated+1, gatherCounter(t, "caco_argocd_secrets_updated_total"))
+
+	assert.Nil(t, c.Delete(context.Background(), &corev1.Secret{ObjectMeta: capiSecret.ObjectMeta}))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, deleted+1, gatherCounter(t, "caco_argocd_secrets_deleted_total"))
+	assert.Equal(t, created+1, gatherCounter(t, "caco_argocd_secrets_created_total"))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+	// The manager serves the metrics registry on /metrics the same way.
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	for _, name := range []string{"caco_argocd_secrets_created_total", "caco_argocd_secrets_updated_total", "caco_argocd_secrets_deleted_total"} {
+		assert.Contains(t, rec.Body.String(), "\n"+name+" ")
+	}
+}
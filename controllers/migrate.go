@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LabelMigration relabels ArgoSecrets owned under a legacy label scheme, ie. carrying
+// <From>owned: "true", to the current one by renaming every label prefixed with From
+// to the same label prefixed with To (eg. capi2argo/ to capi-to-argocd/).
+type LabelMigration struct {
+	Client client.Client
+	From   string
+	To     string
+	Log    logr.Logger
+	// DryRun logs the ArgoSecrets that would be relabeled instead of updating them.
+	DryRun bool
+}
+
+// Start implements manager.Runnable, migrating legacy-owned ArgoSecrets once. A failed
+// migration is logged and never stops the manager, the ArgoSecrets it left behind are
+// migrated on the next start.
+func (m *LabelMigration) Start(ctx context.Context) error {
+	if err := m.Migrate(ctx); err != nil {
+		m.Log.Error(err, "Label migration failed")
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader writes
+// ArgoSecrets, so only the leader migrates them.
+func (m *LabelMigration) NeedLeaderElection() bool {
+	return true
+}
+
+// Migrate relabels all legacy-owned ArgoSecrets, once reconciles are unpaused. Migrated
+// ArgoSecrets lose their legacy labels, so running it again is a no-op.
+func (m *LabelMigration) Migrate(ctx context.Context) error {
+	if !waitUnpaused(ctx, m.Client, m.Log) {
+		return nil
+	}
+	secretList := &corev1.SecretList{}
+	if err := m.Client.List(ctx, secretList, client.MatchingLabels{m.From + "owned": "true"}); err != nil {
+		m.Log.Error(err, "Failed to list legacy-owned ArgoSecrets")
+		return err
+	}
+	for i := range secretList.Items {
+		s := &secretList.Items[i]
+		if m.DryRun {
+			migrateLabels(s.Labels, m.From, m.To)
+			m.Log.Info("Dry-run, would migrate labels of ArgoSecret", "secret", client.ObjectKeyFromObject(s), "labels", renderLabels(s.Labels))
+			continue
+		}
+		attempt := 0
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// A conflict means the ArgoSecret changed under us, so start over from its latest state.
+			if attempt > 0 {
+				if err := m.Client.Get(ctx, client.ObjectKeyFromObject(s), s); err != nil {
+					return err
+				}
+			}
+			attempt++
+			migrateLabels(s.Labels, m.From, m.To)
+			return m.Client.Update(ctx, s)
+		})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			m.Log.Error(err, "Failed to migrate labels of ArgoSecret", "secret", client.ObjectKeyFromObject(s))
+			return err
+		}
+		m.Log.Info("Migrated labels of ArgoSecret", "secret", client.ObjectKeyFromObject(s))
+	}
+	return nil
+}
+
+// migrateLabels renames the from-prefixed labels of l to to-prefixed ones, in-place.
+// Labels already present under the to prefix win over their legacy counterpart.
+func migrateLabels(l map[string]string, from string, to string) {
+	legacy := map[string]string{}
+	for key, value := range l {
+		if suffix, ok := strings.CutPrefix(key, from); ok {
+			legacy[suffix] = value
+			delete(l, key)
+		}
+	}
+	for suffix, value := range legacy {
+		if _, exists := l[to+suffix]; !exists {
+			l[to+suffix] = value
+		}
+	}
+}
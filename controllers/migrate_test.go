@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestLabelMigration(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	legacySecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster-test",
+		Namespace: ArgoNamespace,
+		Labels: map[string]string{
+			"argocd.argoproj.io/secret-type":   "cluster",
+			"capi2argo/owned":                  "true",
+			"capi2argo/cluster-secret-name":    "test-kubeconfig",
+			"capi2argo/cluster-namespace":      TestNamespace,
+			"capi-to-argocd/cluster-namespace": TestNamespace,
+			"team":                             "platform",
+		},
+	}}
+	r, c := MockReconciler(legacySecret)
+	m := &LabelMigration{Client: c, From: "capi2argo/", To: "capi-to-argocd/", Log: TestLog}
+
+	assert.Nil(t, m.Migrate(context.Background()))
+	migrated := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(legacySecret), migrated))
+	assert.Equal(t, map[string]string{
+		"argocd.argoproj.io/secret-type":     "cluster",
+		"capi-to-argocd/owned":               "true",
+		"capi-to-argocd/cluster-secret-name": "test-kubeconfig",
+		"capi-to-argocd/cluster-namespace":   TestNamespace,
+		"team":                               "platform",
+	}, migrated.Labels)
+	assert.Nil(t, ValidateObjectOwner(*migrated))
+
+	// Migrating again is a no-op.
+	assert.Nil(t, m.Migrate(context.Background()))
+	again := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(legacySecret), again))
+	assert.Equal(t, migrated.ResourceVersion, again.ResourceVersion)
+
+	// The migrated ArgoSecret is garbage collected once its source is gone.
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(legacySecret), again)))
+}
+
+// concurrentLabelClient labels Secrets right before their first Update, like a concurrent
+// writer would.
+type concurrentLabelClient struct {
+	*MockClient
+	labeled bool
+}
+
+func (c *concurrentLabelClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if !c.labeled {
+		c.labeled = true
+		latest := &corev1.Secret{}
+		if err := c.MockClient.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+			return err
+		}
+		latest.Labels["team"] = "platform"
+		if err := c.MockClient.Update(ctx, latest); err != nil {
+			return err
+		}
+	}
+	return c.MockClient.Update(ctx, obj, opts...)
+}
+
+func TestLabelMigrationConflict(t *testing.T) {
+	legacySecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster-test",
+		Namespace: ArgoNamespace,
+		Labels:    map[string]string{"capi2argo/owned": "true"},
+	}}
+	c := NewMockClient(legacySecret)
+	m := &LabelMigration{Client: &concurrentLabelClient{MockClient: c}, From: "capi2argo/", To: "capi-to-argocd/", Log: TestLog}
+
+	// The ArgoSecret is labeled by someone else meanwhile, so its latest state is migrated.
+	assert.Nil(t, m.Migrate(context.Background()))
+	migrated := &corev1.Secret{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(legacySecret), migrated))
+	assert.Equal(t, map[string]string{"capi-to-argocd/owned": "true", "team": "platform"}, migrated.Labels)
+
+	// A failed migration does not stop the manager.
+	c = NewMockClient(legacySecret)
+	c.OnUpdate = func(client.Object) error { return errors.NewServiceUnavailable("test") }
+	m.Client = c
+	assert.NotNil(t, m.Migrate(context.Background()))
+	assert.Nil(t, m.Start(context.Background()))
+}
+
+func TestMigrateLabels(t *testing.T) {
+	t.Parallel()
+	l := map[string]string{"old/a": "1", "old/b": "legacy", "new/b": "current", "other": "x"}
+	migrateLabels(l, "old/", "new/")
+	assert.Equal(t, map[string]string{"new/a": "1", "new/b": "current", "other": "x"}, l)
+}
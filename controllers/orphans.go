@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OrphanSweeper garbage collects, once, the ArgoSecrets whose source was deleted while
+// CACO was not running, and whose deletion event was therefore never reconciled.
+type OrphanSweeper struct {
+	Capi2Argo *Capi2Argo
+	// ConfigMaps also counts kubeconfig ConfigMaps as sources, along with CAPI secrets.
+	ConfigMaps bool
+}
+
+// Start implements manager.Runnable, sweeping orphaned ArgoSecrets once the caches synced.
+// A failed sweep is logged and never stops the manager, the orphans it left behind are swept
+// on the next start.
+func (o *OrphanSweeper) Start(ctx context.Context) error {
+	if err := o.Sweep(ctx); err != nil {
+		o.Capi2Argo.Log.Error(err, "Orphan sweep failed")
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader writes
+// ArgoSecrets, so only the leader sweeps them.
+func (o *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Sweep garbage collects the ArgoSecrets of every source that no longer exists, and logs a
+// summary once done. It waits for reconciles to be unpaused, and is a no-op while garbage
+// collection is disabled.
+func (o *OrphanSweeper) Sweep(ctx context.Context) error {
+	if !waitUnpaused(ctx, o.Capi2Argo.Client, o.Capi2Argo.Log) {
+		return nil
+	}
+	summary := &runSummary{}
+	start := time.Now()
+	err := o.sweep(withRunSummary(ctx, summary))
+	summary.log(o.Capi2Argo.Log, "Orphan sweep completed", time.Since(start))
+	return err
+}
+
+func (o *OrphanSweeper) sweep(ctx context.Context) error {
+	r := o.Capi2Argo
+	if !CurrentConfig().EnableGarbageCollection {
+		r.Log.Info("Garbage collection is disabled, skipping orphan sweep")
+		return nil
+	}
+	secretList := &corev1.SecretList{}
+	if err := r.List(ctx, secretList, client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+		r.Log.Error(err, "Failed to list ArgoSecrets")
+		return err
+	}
+	sources := map[types.NamespacedName]bool{}
+	for _, s := range secretList.Items {
+		// ArgoSecrets of ClusterRegistrations carry no source secret name.
+		name, ok := s.Labels["capi-to-argocd/cluster-secret-name"]
+		if !ok {
+			continue
+		}
+		sources[types.NamespacedName{Name: name, Namespace: s.Labels["capi-to-argocd/cluster-namespace"]}] = true
+	}
+	// A failing source does not hold up the others.
+	var errs []error
+	for nn := range sources {
+		exists, err := o.sourceExists(ctx, nn)
+		if err != nil {
+			r.Log.Error(err, "Failed to fetch source of ArgoSecret", "source", nn)
+			countRunOp(ctx, runErrored)
+			errs = append(errs, err)
+			continue
+		}
+		if exists {
+			continue
+		}
+		log := r.Log.WithValues("source", nn)
+		log.Info("Source of ArgoSecret is gone, garbage collecting orphan...")
+		if err := r.garbageCollect(ctx, log, nn, nil); err != nil {
+			countRunOp(ctx, runErrored)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o *OrphanSweeper) sourceExists(ctx context.Context, nn types.NamespacedName) (bool, error) {
+	sources := []client.Object{&corev1.Secret{}}
+	if o.ConfigMaps {
+		sources = append(sources, &corev1.ConfigMap{})
+	}
+	for _, source := range sources {
+		err := o.Capi2Argo.Get(ctx, nn, source)
+		if err == nil {
+			return true, nil
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
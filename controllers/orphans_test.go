@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func mockOwnedArgoSecret(name string, source string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: ArgoNamespace,
+		Labels: map[string]string{
+			"argocd.argoproj.io/secret-type":     "cluster",
+			"capi-to-argocd/owned":               "true",
+			"capi-to-argocd/cluster-secret-name": source,
+			"capi-to-argocd/cluster-namespace":   TestNamespace,
+		},
+	}}
+}
+
+func TestOrphanSweeper(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+
+	orphan := mockOwnedArgoSecret("cluster-gone", "gone-kubeconfig")
+	live := mockOwnedArgoSecret("cluster-test", "test-kubeconfig")
+	fromConfigMap := mockOwnedArgoSecret("cluster-cm", "cm-kubeconfig")
+	protected := mockOwnedArgoSecret("cluster-kept", "kept-kubeconfig")
+	protected.Annotations = map[string]string{protectedAnnotation: "true"}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-kubeconfig", Namespace: TestNamespace}}
+	r, c := MockReconciler(orphan, live, fromConfigMap, protected, cm,
+		MockCapiSecret(true, true, true, "test-kubeconfig", TestNamespace))
+	o := &OrphanSweeper{Capi2Argo: r, ConfigMaps: true}
+
+	// Nothing is swept while garbage collection is disabled.
+	EnableGarbageCollection = false
+	assert.Nil(t, o.Sweep(context.Background()))
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(orphan), &corev1.Secret{}))
+
+	EnableGarbageCollection = true
+	assert.Nil(t, o.Sweep(context.Background()))
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(orphan), &corev1.Secret{})))
+	for _, kept := range []*corev1.Secret{live, fromConfigMap, protected} {
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(kept), &corev1.Secret{}), kept.Name)
+	}
+
+	// Without ConfigMaps, the ArgoSecret of a kubeconfig ConfigMap is an orphan.
+	o.ConfigMaps = false
+	assert.Nil(t, o.Sweep(context.Background()))
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(fromConfigMap), &corev1.Secret{})))
+}
+
+func TestOrphanSweeperSummary(t *testing.T) {
+	oldConf := EnableGarbageCollection
+	defer func() { EnableGarbageCollection = oldConf }()
+	EnableGarbageCollection = true
+
+	protected := mockOwnedArgoSecret("cluster-kept", "kept-kubeconfig")
+	protected.Annotations = map[string]string{protectedAnnotation: "true"}
+	r, c := MockReconciler(
+		mockOwnedArgoSecret("cluster-gone", "gone-kubeconfig"),
+		mockOwnedArgoSecret("cluster-other", "other-kubeconfig"),
+		mockOwnedArgoSecret("cluster-test", "test-kubeconfig"),
+		protected,
+		MockCapiSecret(true, true, true, "test-kubeconfig", TestNamespace))
+	o := &OrphanSweeper{Capi2Argo: r}
+
+	// One orphan fails to be deleted, and does not hold up the others.
+	c.OnDelete = func(obj client.Object) error {
+		if obj.GetName() == "cluster-other" {
+			return errors.NewServiceUnavailable("test")
+		}
+		return nil
+	}
+	summary := &runSummary{}
+	assert.NotNil(t, o.sweep(withRunSummary(context.Background(), summary)))
+	assert.Equal(t, int64(0), summary.count(runCreated))
+	assert.Equal(t, int64(0), summary.count(runUpdated))
+	assert.Equal(t, int64(1), summary.count(runDeleted))
+	assert.Equal(t, int64(1), summary.count(runSkipped))
+	assert.Equal(t, int64(1), summary.count(runErrored))
+
+	// A failed sweep does not stop the manager.
+	assert.Nil(t, o.Start(context.Background()))
+
+	// The summary is logged once the sweep completes.
+	log, sink := NewMockLogger(0)
+	r.Log = log
+	c.OnDelete = nil
+	assert.Nil(t, o.Sweep(context.Background()))
+	assert.Contains(t, sink.Messages(), "Orphan sweep completed")
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// paused returns true when OperatorNamespace carries pausedAnnotation, in which case no
+// writer of ArgoSecrets may write. The namespace is read through c, whose cache only holds
+// OperatorNamespace (see pauseCacheByObject), within PausedCheckTimeout. Failing to read it
+// does not pause, but is logged as an error since the kill switch is then ineffective.
+func paused(ctx context.Context, c client.Reader, log logr.Logger) bool {
+	if OperatorNamespace == "" {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, PausedCheckTimeout)
+	defer cancel()
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: OperatorNamespace}, &ns); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to check whether reconciles are paused, going on unpaused", "namespace", OperatorNamespace)
+		}
+		return false
+	}
+	return ns.Annotations[pausedAnnotation] == "true"
+}
+
+// waitUnpaused blocks, polling every PausedRequeueAfter, for as long as reconciles are
+// paused. It returns false when ctx is done meanwhile. It serves the one-shot writers, which
+// cannot requeue.
+func waitUnpaused(ctx context.Context, c client.Reader, log logr.Logger) bool {
+	for paused(ctx, c, log) {
+		log.Info("Reconciles are paused, waiting...", "namespace", OperatorNamespace, "after", PausedRequeueAfter)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(PausedRequeueAfter):
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// pauseCacheByObject limits the cache of Namespaces to OperatorNamespace, so that checking
+// for pausedAnnotation neither hits the API server on every reconcile nor watches every
+// Namespace.
+func pauseCacheByObject() map[client.Object]cache.ByObject {
+	return map[client.Object]cache.ByObject{
+		&corev1.Namespace{}: {Field: fields.OneTermEqualSelector("metadata.name", OperatorNamespace)},
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// mockPausedNamespace returns the operator namespace, annotated with pausedAnnotation.
+func mockPausedNamespace() *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        OperatorNamespace,
+		Annotations: map[string]string{pausedAnnotation: "true"},
+	}}
+}
+
+func TestWritersPaused(t *testing.T) {
+	oldConf, oldGC, oldRequeue := OperatorNamespace, EnableGarbageCollection, PausedRequeueAfter
+	defer func() { OperatorNamespace, EnableGarbageCollection, PausedRequeueAfter = oldConf, oldGC, oldRequeue }()
+	OperatorNamespace, EnableGarbageCollection, PausedRequeueAfter = "caco-system", true, time.Hour
+
+	t.Run("ConfigMap reconciler", func(t *testing.T) {
+		cm := MockKubeConfigMap("test-kubeconfig", TestNamespace)
+		c2a, c := MockReconciler(cm, mockPausedNamespace())
+		r := &KubeConfigMapReconciler{Capi2Argo: *c2a}
+		res, err := r.Reconcile(context.Background(), MockReconcileReq(cm.Name, cm.Namespace))
+		assert.Nil(t, err)
+		assert.Equal(t, PausedRequeueAfter, res.RequeueAfter)
+		assert.NotNil(t, c.Get(context.Background(), types.NamespacedName{Name: "cluster-test", Namespace: ArgoNamespace}, &corev1.Secret{}))
+	})
+
+	t.Run("ClusterRegistration reconciler", func(t *testing.T) {
+		argoSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-gone",
+			Namespace: ArgoNamespace,
+			Labels: map[string]string{
+				"capi-to-argocd/owned":             "true",
+				clusterRegistrationNameLabel:       "gone",
+				"capi-to-argocd/cluster-namespace": TestNamespace,
+			},
+		}}
+		c := NewMockClient(argoSecret, mockPausedNamespace())
+		r := &ClusterRegistrationReconciler{Client: c, Log: TestLog}
+		res, err := r.Reconcile(context.Background(), MockReconcileReq("gone", TestNamespace))
+		assert.Nil(t, err)
+		assert.Equal(t, PausedRequeueAfter, res.RequeueAfter)
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(argoSecret), &corev1.Secret{}))
+	})
+
+	t.Run("moved ArgoSecrets pruning", func(t *testing.T) {
+		labels := map[string]string{
+			"capi-to-argocd/owned":               "true",
+			"capi-to-argocd/cluster-secret-name": "test-kubeconfig",
+			"capi-to-argocd/cluster-namespace":   TestNamespace,
+		}
+		moved := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "cluster-test", Namespace: "argocd-old", Labels: labels}}
+		replacement := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "cluster-test", Namespace: ArgoNamespace, Labels: labels}}
+		c := NewMockClient(moved, replacement, mockPausedNamespace())
+		r := &ConfigReconciler{Client: c, Log: TestLog, movedFrom: map[string]bool{"argocd-old": true}}
+		res, err := r.pruneMoved(context.Background(), TestLog)
+		assert.Nil(t, err)
+		assert.Equal(t, PausedRequeueAfter, res.RequeueAfter)
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(moved), &corev1.Secret{}))
+		assert.Equal(t, map[string]bool{"argocd-old": true}, r.movedFrom)
+	})
+
+	// One-shot writers wait for reconciles to be unpaused, or their context to be done.
+	t.Run("orphan sweep", func(t *testing.T) {
+		orphan := mockOwnedArgoSecret("cluster-gone", "gone-kubeconfig")
+		r, c := MockReconciler(orphan, mockPausedNamespace())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Nil(t, (&OrphanSweeper{Capi2Argo: r}).Sweep(ctx))
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(orphan), &corev1.Secret{}))
+	})
+
+	t.Run("label migration", func(t *testing.T) {
+		legacySecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-test",
+			Namespace: ArgoNamespace,
+			Labels:    map[string]string{"capi2argo/owned": "true"},
+		}}
+		c := NewMockClient(legacySecret, mockPausedNamespace())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		m := &LabelMigration{Client: c, From: "capi2argo/", To: "capi-to-argocd/", Log: TestLog}
+		assert.Nil(t, m.Migrate(ctx))
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(legacySecret), legacySecret))
+		assert.Equal(t, "true", legacySecret.Labels["capi2argo/owned"])
+	})
+}
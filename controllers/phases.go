@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PhaseReporter exports the CAPI Cluster phase of the registered clusters as
+// caco_clusters_by_phase every Interval, to correlate ArgoCD registrations with the CAPI
+// lifecycle.
+type PhaseReporter struct {
+	Client   client.Client
+	Interval time.Duration
+	Log      logr.Logger
+}
+
+// Report counts the registered clusters by the phase of their source Cluster. Clusters
+// whose Cluster is gone or in an unexpected phase are counted as Unknown.
+func (p *PhaseReporter) Report(ctx context.Context) error {
+	secretList := &corev1.SecretList{}
+	if err := p.Client.List(ctx, secretList, client.MatchingLabels{"capi-to-argocd/owned": "true"}); err != nil {
+		return err
+	}
+	// Sources registered several times (eg. one ArgoSecret per context) count once.
+	sources := map[types.NamespacedName]bool{}
+	for i := range secretList.Items {
+		s := &secretList.Items[i]
+		name, ok := s.Labels["capi-to-argocd/cluster-secret-name"]
+		if !ok || isShadowSecret(s) {
+			continue
+		}
+		sources[types.NamespacedName{Name: name, Namespace: s.Labels["capi-to-argocd/cluster-namespace"]}] = true
+	}
+
+	phases := map[clusterv1.ClusterPhase]int{}
+	for nn := range sources {
+		clusterName := strings.TrimSuffix(nn.Name, "-kubeconfig")
+		capiSecret := &corev1.Secret{}
+		if err := p.Client.Get(ctx, nn, capiSecret); err == nil {
+			clusterName = capiSecretClusterName(capiSecret)
+		} else if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		cluster := &clusterv1.Cluster{}
+		if err := p.Client.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: nn.Namespace}, cluster); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		phases[cluster.Status.GetTypedPhase()]++
+	}
+
+	clustersByPhase.Reset()
+	for phase, n := range phases {
+		clustersByPhase.WithLabelValues(string(phase)).Set(float64(n))
+	}
+	return nil
+}
+
+// Start implements manager.Runnable, reporting the cluster phases every Interval until ctx
+// is done.
+func (p *PhaseReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.Report(ctx); err != nil {
+				p.Log.Error(err, "Failed to report cluster phases")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader reports,
+// so that replicas do not export the same clusters twice.
+func (p *PhaseReporter) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPhaseReporter(t *testing.T) {
+	withPhase := func(name string, phase clusterv1.ClusterPhase) *clusterv1.Cluster {
+		c := MockCluster(name, TestNamespace, nil, nil)
+		c.Status.Phase = string(phase)
+		return c
+	}
+	objs := []client.Object{
+		withPhase("a", clusterv1.ClusterPhaseProvisioned),
+		withPhase("b", clusterv1.ClusterPhaseProvisioned),
+		withPhase("c", clusterv1.ClusterPhaseProvisioning),
+	}
+	for _, name := range []string{"a", "b", "c", "gone"} {
+		s := MockCapiSecret(validMock, validType, validKey, name+"-kubeconfig", TestNamespace)
+		s.Labels[clusterv1.ClusterNameLabel] = name
+		objs = append(objs, s)
+	}
+	r, c := MockReconciler(objs...)
+	for _, name := range []string{"a", "b", "c", "gone"} {
+		_, err := r.Reconcile(context.Background(), MockReconcileReq(name+"-kubeconfig", TestNamespace))
+		assert.Nil(t, err)
+	}
+
+	p := &PhaseReporter{Client: c, Log: TestLog}
+	assert.Nil(t, p.Report(context.Background()))
+	assert.Equal(t, 2.0, MockGaugeValue(clustersByPhase.WithLabelValues(string(clusterv1.ClusterPhaseProvisioned))))
+	assert.Equal(t, 1.0, MockGaugeValue(clustersByPhase.WithLabelValues(string(clusterv1.ClusterPhaseProvisioning))))
+	// Registered clusters without a Cluster are Unknown.
+	assert.Equal(t, 1.0, MockGaugeValue(clustersByPhase.WithLabelValues(string(clusterv1.ClusterPhaseUnknown))))
+
+	// Phases no cluster is in anymore are dropped.
+	cluster := &clusterv1.Cluster{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Name: "c", Namespace: TestNamespace}, cluster))
+	cluster.Status.Phase = string(clusterv1.ClusterPhaseProvisioned)
+	assert.Nil(t, c.Update(context.Background(), cluster))
+	assert.Nil(t, p.Report(context.Background()))
+	assert.Equal(t, 3.0, MockGaugeValue(clustersByPhase.WithLabelValues(string(clusterv1.ClusterPhaseProvisioned))))
+	assert.Equal(t, 0.0, MockGaugeValue(clustersByPhase.WithLabelValues(string(clusterv1.ClusterPhaseProvisioning))))
+}
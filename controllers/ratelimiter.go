@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// MaxReconcileBackoff caps the per-item exponential backoff of failing reconciles.
+var MaxReconcileBackoff = 1000 * time.Second
+
+// newRateLimiter returns the controller-runtime default rate limiter, with its per-item
+// backoff capped at MaxReconcileBackoff.
+func newRateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](5*time.Millisecond, MaxReconcileBackoff),
+		// 10 qps, 100 bucket size, only limiting the overall retry speed.
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
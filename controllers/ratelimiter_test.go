@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterMaxBackoff(t *testing.T) {
+	oldConf := MaxReconcileBackoff
+	defer func() { MaxReconcileBackoff = oldConf }()
+	MaxReconcileBackoff = 100 * time.Millisecond
+
+	rl := newRateLimiter()
+	req := MockReconcileReq("test", "test")
+
+	var last time.Duration
+	for i := 0; i < 20; i++ {
+		last = rl.When(req)
+		assert.LessOrEqual(t, last, MaxReconcileBackoff)
+	}
+	assert.Equal(t, MaxReconcileBackoff, last)
+
+	rl.Forget(req)
+	assert.Less(t, rl.When(req), MaxReconcileBackoff)
+}
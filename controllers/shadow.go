@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// shadowKey labels the copies of ArgoSecrets written to ShadowNamespace.
+const shadowKey = "capi-to-argocd/shadow"
+
+// ShadowNamespace optionally receives a copy of every ArgoSecret, so that changes can be
+// validated by a second ArgoCD before they go live. Empty disables shadowing.
+var ShadowNamespace string
+
+// isShadowSecret returns true for ArgoSecret copies living in ShadowNamespace.
+func isShadowSecret(s *corev1.Secret) bool {
+	return s.Labels[shadowKey] == "true"
+}
+
+// buildShadowSecret returns the ShadowNamespace copy of argoSecret.
+func buildShadowSecret(argoSecret *corev1.Secret) *corev1.Secret {
+	shadow := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        argoSecret.Name,
+			Namespace:   ShadowNamespace,
+			Labels:      maps.Clone(argoSecret.Labels),
+			Annotations: maps.Clone(argoSecret.Annotations),
+		},
+		Data: maps.Clone(argoSecret.Data),
+	}
+	if shadow.Labels == nil {
+		shadow.Labels = map[string]string{}
+	}
+	shadow.Labels[shadowKey] = "true"
+	return shadow
+}
+
+// syncShadow mirrors argoSecret into ShadowNamespace. The shadow copy is fully derived
+// from argoSecret, so it is replaced as a whole when out-of-sync.
+func (r *Capi2Argo) syncShadow(ctx context.Context, log logr.Logger, argoSecret *corev1.Secret) error {
+	desired := buildShadowSecret(argoSecret)
+	log = log.WithValues("shadow", client.ObjectKeyFromObject(desired))
+	if r.DryRun {
+		log.V(1).Info("Dry-run, not mirroring shadow ArgoSecret")
+		return nil
+	}
+
+	var existing corev1.Secret
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), &existing)
+	if errors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil {
+			log.Error(err, "Failed to create shadow ArgoSecret")
+			return err
+		}
+		log.Info("Created new shadow ArgoSecret")
+		return nil
+	} else if err != nil {
+		log.Error(err, "Failed to fetch shadow ArgoSecret to check if exists")
+		return err
+	}
+
+	if ValidateObjectOwner(existing) != nil || !isShadowSecret(&existing) {
+		log.Info("Shadow ArgoSecret not managed by Controller, skipping...")
+		return nil
+	}
+	if err := ValidateArgoSecretSource(existing, desired); err != nil {
+		log.Error(err, "Skipping shadow ArgoSecret")
+		return nil
+	}
+
+	if reflect.DeepEqual(existing.Data, desired.Data) && reflect.DeepEqual(existing.Labels, desired.Labels) &&
+		reflect.DeepEqual(existing.Annotations, desired.Annotations) {
+		return nil
+	}
+	existing.Data = desired.Data
+	existing.Labels = desired.Labels
+	existing.Annotations = desired.Annotations
+	if err := r.Update(ctx, &existing); err != nil {
+		log.Error(err, "Failed to update shadow ArgoSecret")
+		return err
+	}
+	log.Info("Updated successfully of shadow ArgoSecret")
+	return nil
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// syncStatusAnnotation holds the SyncStatus of the last reconcile on CAPI secrets.
+	syncStatusAnnotation = "capi-to-argocd/status"
+
+	// SyncStatusReady reports that the ArgoSecret is in-sync with the CAPI secret.
+	SyncStatusReady = "Ready"
+	// SyncStatusError reports that the last reconcile failed.
+	SyncStatusError = "Error"
+)
+
+// SyncStatus is a condition-like summary of the last reconcile, since Secrets have no status.
+type SyncStatus struct {
+	Status             string      `json:"status"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// buildSyncStatus returns the SyncStatus annotation value for a reconcile outcome. The
+// transition time of previous is kept as long as the status does not change.
+func buildSyncStatus(previous string, err error, now time.Time) (string, error) {
+	status := SyncStatus{
+		Status:             SyncStatusReady,
+		LastTransitionTime: metav1.NewTime(now.UTC().Truncate(time.Second)),
+	}
+	if err != nil {
+		status.Status = SyncStatusError
+		status.Message = err.Error()
+	}
+
+	var old SyncStatus
+	if json.Unmarshal([]byte(previous), &old) == nil && old.Status == status.Status {
+		status.LastTransitionTime = old.LastTransitionTime
+	}
+
+	v, err := json.Marshal(status)
+	return string(v), err
+}
+
+// updateSyncStatus records the outcome of a reconcile on the CAPI secret. The secret is
+// only patched when the status changes, so the resulting event settles on the next reconcile.
+func (r *Capi2Argo) updateSyncStatus(ctx context.Context, s *corev1.Secret, reconcileErr error) {
+	log := r.Log.WithValues("secret", client.ObjectKeyFromObject(s))
+
+	status, err := buildSyncStatus(s.Annotations[syncStatusAnnotation], reconcileErr, time.Now())
+	if err != nil {
+		log.Error(err, "Failed to build sync status")
+		return
+	}
+	if s.Annotations[syncStatusAnnotation] == status || r.DryRun {
+		return
+	}
+
+	patch := client.MergeFrom(s.DeepCopy())
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[syncStatusAnnotation] = status
+	if err := r.Patch(ctx, s, patch); err != nil {
+		log.Error(err, "Failed to update sync status of CapiSecret")
+		return
+	}
+	if reconcileErr == nil {
+		r.rememberEcho(client.ObjectKeyFromObject(s), s.ResourceVersion)
+	}
+}
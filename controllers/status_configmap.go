@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=create;patch
+
+// StatusReporter aggregates the outcome of the last reconcile of every CAPI secret into a
+// ConfigMap, giving dashboards a single object to watch. Outcomes are recorded in memory
+// and the ConfigMap is written at most once per Interval, only when they changed.
+type StatusReporter struct {
+	Client client.Client
+	// Name is the name of the ConfigMap, which lives in ArgoNamespace, following it when it
+	// changes at runtime.
+	Name     string
+	Interval time.Duration
+	Log      logr.Logger
+	// DryRun logs the ConfigMap data that would be written instead of writing it.
+	DryRun bool
+
+	mu       sync.Mutex
+	outcomes map[types.NamespacedName]bool
+	dirty    bool
+}
+
+// Record records the outcome of a reconcile of the CAPI secret nn. It is a no-op on a nil
+// StatusReporter.
+func (s *StatusReporter) Record(nn types.NamespacedName, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.outcomes == nil {
+		s.outcomes = map[types.NamespacedName]bool{}
+	}
+	synced, known := s.outcomes[nn]
+	if known && synced == (err == nil) {
+		return
+	}
+	s.outcomes[nn] = err == nil
+	s.dirty = true
+}
+
+// Forget drops the CAPI secret nn, eg. once deleted. It is a no-op on a nil StatusReporter.
+func (s *StatusReporter) Forget(nn types.NamespacedName) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, known := s.outcomes[nn]; !known {
+		return
+	}
+	delete(s.outcomes, nn)
+	s.dirty = true
+}
+
+// data returns the ConfigMap data of the recorded outcomes, and whether they changed since
+// the last call.
+func (s *StatusReporter) data() (map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	synced, errored := 0, 0
+	for _, ok := range s.outcomes {
+		if ok {
+			synced++
+		} else {
+			errored++
+		}
+	}
+	dirty := s.dirty
+	s.dirty = false
+	return map[string]string{
+		"synced":  strconv.Itoa(synced),
+		"errored": strconv.Itoa(errored),
+		"ready":   strconv.FormatBool(errored == 0),
+	}, dirty
+}
+
+// Flush writes the recorded outcomes to the ConfigMap, when they changed.
+func (s *StatusReporter) Flush(ctx context.Context) error {
+	data, dirty := s.data()
+	if !dirty {
+		return nil
+	}
+	if err := s.write(ctx, data); err != nil {
+		// Try again on the next flush.
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// configMap returns the key of the ConfigMap in the current ArgoNamespace.
+func (s *StatusReporter) configMap() types.NamespacedName {
+	return types.NamespacedName{Namespace: CurrentConfig().ArgoNamespace, Name: s.Name}
+}
+
+func (s *StatusReporter) write(ctx context.Context, data map[string]string) error {
+	key := s.configMap()
+	if s.DryRun {
+		s.Log.Info("Dry-run, would update status ConfigMap", "configmap", key, "data", data)
+		return nil
+	}
+	// The ConfigMap is patched blindly, as the cache does not hold it (see
+	// configMapCacheByObject), keeping the keys set by others.
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	patch := client.MergeFrom(cm.DeepCopy())
+	cm.Data = data
+	err := s.Client.Patch(ctx, cm, patch)
+	if errors.IsNotFound(err) {
+		cm.Labels = map[string]string{"capi-to-argocd/owned": "true"}
+		return s.Client.Create(ctx, cm)
+	}
+	return err
+}
+
+// Start implements manager.Runnable, flushing the recorded outcomes every Interval until
+// ctx is done.
+func (s *StatusReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				s.Log.Error(err, "Failed to update status ConfigMap", "configmap", s.configMap())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader reconciles,
+// so only the leader has outcomes to report.
+func (s *StatusReporter) NeedLeaderElection() bool {
+	return true
+}
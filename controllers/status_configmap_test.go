@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStatusReporter(t *testing.T) {
+	synced := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	errored := MockCapiSecret(!validMock, validType, validKey, "other-kubeconfig", TestNamespace)
+	r, c := MockReconciler(synced, errored)
+	cmKey := types.NamespacedName{Name: "caco-status", Namespace: ArgoNamespace}
+	r.Status = &StatusReporter{Client: c, Name: cmKey.Name, Log: TestLog}
+
+	for _, s := range []*corev1.Secret{synced, errored} {
+		_, _ = r.Reconcile(context.Background(), MockReconcileReq(s.Name, s.Namespace))
+	}
+	assert.Nil(t, r.Status.Flush(context.Background()))
+	cm := &corev1.ConfigMap{}
+	assert.Nil(t, c.Get(context.Background(), cmKey, cm))
+	assert.Equal(t, map[string]string{"synced": "1", "errored": "1", "ready": "false"}, cm.Data)
+
+	// Unchanged outcomes are not written again.
+	_, _ = r.Reconcile(context.Background(), MockReconcileReq(synced.Name, synced.Namespace))
+	_, dirty := r.Status.data()
+	assert.False(t, dirty)
+
+	// Deleted secrets are dropped from the counts.
+	assert.Nil(t, c.Delete(context.Background(), errored))
+	_, _ = r.Reconcile(context.Background(), MockReconcileReq(errored.Name, errored.Namespace))
+	assert.Nil(t, r.Status.Flush(context.Background()))
+	assert.Nil(t, c.Get(context.Background(), cmKey, cm))
+	assert.Equal(t, map[string]string{"synced": "1", "errored": "0", "ready": "true"}, cm.Data)
+}
+
+func TestStatusReporterNamespaceChange(t *testing.T) {
+	oldConf := CurrentConfig()
+	defer ApplyConfig(oldConf)
+
+	c := NewMockClient()
+	s := &StatusReporter{Client: c, Name: "caco-status", Log: TestLog}
+	s.Record(types.NamespacedName{Name: "test-kubeconfig", Namespace: TestNamespace}, nil)
+
+	// The ConfigMap follows ArgoNamespace as it changes at runtime.
+	newConf := oldConf
+	newConf.ArgoNamespace = "argocd-new"
+	ApplyConfig(newConf)
+	assert.Nil(t, s.Flush(context.Background()))
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "caco-status", Namespace: "argocd-new"}, &corev1.ConfigMap{}))
+}
+
+func TestStatusReporterNil(t *testing.T) {
+	t.Parallel()
+	var s *StatusReporter
+	s.Record(types.NamespacedName{Name: "test"}, nil)
+	s.Forget(types.NamespacedName{Name: "test"})
+}
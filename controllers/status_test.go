@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBuildSyncStatus(t *testing.T) {
+	t.Parallel()
+	then := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := then.Add(time.Hour)
+	ready, err := buildSyncStatus("", nil, then)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		testName               string
+		testPrevious           string
+		testError              error
+		testExpectedStatus     string
+		testExpectedMessage    string
+		testExpectedTransition time.Time
+	}{
+		{"test first ready status", "", nil, SyncStatusReady, "", now},
+		{"test first error status", "", errors.New("invalid KubeConfig"), SyncStatusError, "invalid KubeConfig", now},
+		{"test unchanged ready status", ready, nil, SyncStatusReady, "", then},
+		{"test ready to error transition", ready, errors.New("invalid KubeConfig"), SyncStatusError, "invalid KubeConfig", now},
+		{"test non-valid previous status", "tester", nil, SyncStatusReady, "", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			v, err := buildSyncStatus(tt.testPrevious, tt.testError, now)
+			assert.Nil(t, err)
+			var status SyncStatus
+			assert.Nil(t, json.Unmarshal([]byte(v), &status))
+			assert.Equal(t, tt.testExpectedStatus, status.Status)
+			assert.Equal(t, tt.testExpectedMessage, status.Message)
+			assert.True(t, tt.testExpectedTransition.Equal(status.LastTransitionTime.Time))
+		})
+	}
+}
+
+func TestReconcileSyncStatus(t *testing.T) {
+	tests := []struct {
+		testName            string
+		testMock            *corev1.Secret
+		testExpectedStatus  string
+		testExpectedMessage string
+	}{
+		{"test status of valid secret", MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace), SyncStatusReady, ""},
+		{"test status of secret with non-valid kubeconfig", MockCapiSecret(!validMock, validType, validKey, "test-kubeconfig", TestNamespace), SyncStatusError, "invalid KubeConfig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r, c := MockReconciler(tt.testMock)
+			_, _ = r.Reconcile(context.Background(), MockReconcileReq(tt.testMock.Name, tt.testMock.Namespace))
+
+			s := &corev1.Secret{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(tt.testMock), s))
+			var status SyncStatus
+			assert.Nil(t, json.Unmarshal([]byte(s.Annotations[syncStatusAnnotation]), &status))
+			assert.Equal(t, tt.testExpectedStatus, status.Status)
+			assert.Equal(t, tt.testExpectedMessage, status.Message)
+
+			// An unchanged outcome does not write the secret again.
+			_, _ = r.Reconcile(context.Background(), MockReconcileReq(tt.testMock.Name, tt.testMock.Namespace))
+			again := &corev1.Secret{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(tt.testMock), again))
+			assert.Equal(t, s.ResourceVersion, again.ResourceVersion)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// runOp is an operation on ArgoSecrets counted in a runSummary.
+type runOp int
+
+const (
+	runCreated runOp = iota
+	runUpdated
+	runDeleted
+	runSkipped
+	runErrored
+)
+
+// runSummary counts the operations on ArgoSecrets of a run over many sources, eg. an orphan
+// sweep, so that the run can be summed up once completed.
+type runSummary struct {
+	ops [runErrored + 1]atomic.Int64
+}
+
+// runSummaryKey is the context key of the runSummary of a run.
+type runSummaryKey struct{}
+
+// withRunSummary returns a copy of ctx counting operations into s.
+func withRunSummary(ctx context.Context, s *runSummary) context.Context {
+	return context.WithValue(ctx, runSummaryKey{}, s)
+}
+
+// countRunOp counts op in the runSummary of ctx, if any.
+func countRunOp(ctx context.Context, op runOp) {
+	if s, ok := ctx.Value(runSummaryKey{}).(*runSummary); ok {
+		s.ops[op].Add(1)
+	}
+}
+
+// count returns the number of op operations counted so far.
+func (s *runSummary) count(op runOp) int64 {
+	return s.ops[op].Load()
+}
+
+// log logs a single summary line of the run named msg, taking d.
+func (s *runSummary) log(log logr.Logger, msg string, d time.Duration) {
+	log.Info(msg,
+		"created", s.count(runCreated),
+		"updated", s.count(runUpdated),
+		"deleted", s.count(runDeleted),
+		"skipped", s.count(runSkipped),
+		"errored", s.count(runErrored),
+		"duration", d.String())
+}
+
+// outcome sums up a run over a single source ending with err, as the first of errored,
+// created, updated, deleted or skipped it went through, or unchanged.
+func (s *runSummary) outcome(err error) string {
+	if err != nil || s.count(runErrored) > 0 {
+		return "errored"
+	}
+	for _, o := range []struct {
+		op   runOp
+		name string
+	}{{runCreated, "created"}, {runUpdated, "updated"}, {runDeleted, "deleted"}, {runSkipped, "skipped"}} {
+		if s.count(o.op) > 0 {
+			return o.name
+		}
+	}
+	return "unchanged"
+}
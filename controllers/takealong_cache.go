@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// takeAlongCacheEntry holds the take-along labels and annotations built from a Cluster,
+// along with the version of the Cluster and the settings they were built from.
+type takeAlongCacheEntry struct {
+	uid             types.UID
+	resourceVersion string
+	policy          string
+	maxLabels       int
+	warnEmpty       bool
+
+	takeAlongLabels      map[string]string
+	takeAlongAnnotations map[string]string
+	// errList and counts are replayed on every use, so that warnings and metrics are not
+	// limited to the first build.
+	errList []string
+	counts  takeAlongCounts
+}
+
+// takeAlongCache remembers take-along labels and annotations per Cluster, so that
+// reconciles of an unchanged Cluster, eg. on resync, do not rebuild them. Entries are evicted once the
+// Cluster or its source is found gone, see forgetTakeAlong.
+var takeAlongCache = struct {
+	sync.Mutex
+	entries map[types.NamespacedName]takeAlongCacheEntry
+}{entries: map[types.NamespacedName]takeAlongCacheEntry{}}
+
+// buildCachedTakeAlong returns the take-along labels and annotations of a cluster, reusing
+// the previous result while its UID and resourceVersion are unchanged. The returned maps
+// are shared with the cache and must not be modified. hit reports cache use. The
+// take-along metrics are recorded and errList returned either way.
+func buildCachedTakeAlong(cluster *clusterv1.Cluster) (takeAlongLabels map[string]string, takeAlongAnnotations map[string]string, errList []string, hit bool) {
+	key := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
+
+	takeAlongCache.Lock()
+	e, ok := takeAlongCache.entries[key]
+	takeAlongCache.Unlock()
+	if ok && e.uid == cluster.UID && e.resourceVersion == cluster.ResourceVersion &&
+		e.policy == OverlongLabelPolicy && e.maxLabels == MaxTakeAlongLabels && e.warnEmpty == WarnEmptyTakeAlongValues {
+		e.counts.record()
+		return e.takeAlongLabels, e.takeAlongAnnotations, e.errList, true
+	}
+
+	takeAlongLabels, labelErrs, counts := buildCountedTakeAlongLabels(cluster)
+	counts.record()
+	takeAlongAnnotations, annotationErrs := buildTakeAlongAnnotations(cluster)
+	errList = append(labelErrs, annotationErrs...)
+
+	// Nothing to remember for clusters that could not be fetched, nor for unversioned ones.
+	if cluster.Name != "" && cluster.ResourceVersion != "" {
+		takeAlongCache.Lock()
+		takeAlongCache.entries[key] = takeAlongCacheEntry{
+			uid:                  cluster.UID,
+			resourceVersion:      cluster.ResourceVersion,
+			policy:               OverlongLabelPolicy,
+			maxLabels:            MaxTakeAlongLabels,
+			warnEmpty:            WarnEmptyTakeAlongValues,
+			takeAlongLabels:      takeAlongLabels,
+			takeAlongAnnotations: takeAlongAnnotations,
+			errList:              errList,
+			counts:               counts,
+		}
+		takeAlongCache.Unlock()
+	}
+	return takeAlongLabels, takeAlongAnnotations, errList, false
+}
+
+// forgetTakeAlong evicts the take-along labels and annotations remembered for the Cluster nn,
+// eg. once deleted.
+func forgetTakeAlong(nn types.NamespacedName) {
+	takeAlongCache.Lock()
+	defer takeAlongCache.Unlock()
+	delete(takeAlongCache.entries, nn)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBuildCachedTakeAlong(t *testing.T) {
+	t.Parallel()
+	cluster := MockCluster("cached", "test", map[string]string{
+		"foo":                       "bar",
+		clusterTakeAlongKey + "foo": "",
+	}, nil)
+	cluster.ResourceVersion = "1"
+
+	l, _, _, hit := buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+	assert.Equal(t, "bar", l["foo"])
+
+	// Unchanged Clusters reuse the previous result.
+	l, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.True(t, hit)
+	assert.Equal(t, "bar", l["foo"])
+
+	// Any update is picked up.
+	cluster.Labels["foo"] = "baz"
+	cluster.ResourceVersion = "2"
+	l, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+	assert.Equal(t, "baz", l["foo"])
+	_, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.True(t, hit)
+
+	// So is a recreated Cluster.
+	cluster.UID = "recreated"
+	_, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+
+	// Unversioned Clusters are not remembered.
+	cluster.ResourceVersion = ""
+	_, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+	_, _, _, hit = buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+}
+
+func TestBuildCachedTakeAlongReplaysWarnings(t *testing.T) {
+	oldWarn, oldMax := WarnEmptyTakeAlongValues, MaxTakeAlongLabels
+	defer func() { WarnEmptyTakeAlongValues, MaxTakeAlongLabels = oldWarn, oldMax }()
+	WarnEmptyTakeAlongValues, MaxTakeAlongLabels = true, 1
+
+	cluster := MockCluster("cached-warnings", "test", map[string]string{
+		"a":                       "",
+		"b":                       "b",
+		clusterTakeAlongKey + "a": "",
+		clusterTakeAlongKey + "b": "",
+	}, nil)
+	cluster.ResourceVersion = "1"
+	_, _, errList, hit := buildCachedTakeAlong(cluster)
+	assert.False(t, hit)
+	assert.Len(t, errList, 2)
+
+	// Cache hits warn, and count in the metrics, on every build too.
+	empty, dropped := MockCounterValue(takeAlongEmptyValuesTotal), MockCounterValue(takeAlongLabelsDroppedTotal)
+	_, _, cachedErrList, hit := buildCachedTakeAlong(cluster)
+	assert.True(t, hit)
+	assert.Equal(t, errList, cachedErrList)
+	assert.Equal(t, empty+1, MockCounterValue(takeAlongEmptyValuesTotal))
+	assert.Equal(t, dropped+1, MockCounterValue(takeAlongLabelsDroppedTotal))
+}
+
+// takeAlongCached reports whether take-along labels are remembered for the Cluster nn.
+func takeAlongCached(nn types.NamespacedName) bool {
+	takeAlongCache.Lock()
+	defer takeAlongCache.Unlock()
+	_, ok := takeAlongCache.entries[nn]
+	return ok
+}
+
+func TestTakeAlongCacheEviction(t *testing.T) {
+	capiSecret := MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace)
+	cluster := MockCluster("test", TestNamespace, map[string]string{"foo": "bar"}, nil)
+	r, c := MockReconciler(capiSecret, cluster)
+	req := MockReconcileReq(capiSecret.Name, capiSecret.Namespace)
+	nn := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
+
+	_, err := r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, takeAlongCached(nn))
+
+	// Deleted Clusters are evicted.
+	assert.Nil(t, c.Delete(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.False(t, takeAlongCached(nn))
+
+	// So are the Clusters of deleted CAPI secrets, whether garbage collected or not.
+	cluster.ResourceVersion = ""
+	assert.Nil(t, c.Create(context.Background(), cluster))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, takeAlongCached(nn))
+	assert.Nil(t, c.Delete(context.Background(), capiSecret))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.False(t, takeAlongCached(nn))
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tracerName names the tracer of the spans of CACO.
+const tracerName = "github.com/dntosas/capi2argo-cluster-operator/controllers"
+
+// NewTracerProvider registers a TracerProvider exporting spans over OTLP/HTTP to endpoint,
+// eg. http://otel-collector:4318. It is to be shut down on exit, flushing pending spans.
+func NewTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "capi2argo-cluster-operator"))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
+
+// startSpan starts a span as a child of the span of ctx, if any. Spans are dropped unless a
+// TracerProvider is registered, see NewTracerProvider.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// WithTracing returns a client tracing its API calls as child spans, eg. of the span of
+// the reconcile they are made from.
+func WithTracing(c client.Client) client.Client {
+	return &tracingClient{Client: c}
+}
+
+// tracingClient traces the API calls of the reconcilers, see WithTracing.
+type tracingClient struct {
+	client.Client
+}
+
+// objectAttributes returns the span attributes identifying obj.
+func objectAttributes(obj client.Object, key client.ObjectKey) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("kind", reflect.TypeOf(obj).Elem().Name()),
+		attribute.String("namespace", key.Namespace),
+		attribute.String("name", key.Name),
+	}
+}
+
+// Get implements client.Client. Objects not found are not errors of the span, as sources
+// and ArgoSecrets routinely are.
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	ctx, span := startSpan(ctx, "Get", objectAttributes(obj, key)...)
+	err := c.Client.Get(ctx, key, obj, opts...)
+	endSpan(span, client.IgnoreNotFound(err))
+	return err
+}
+
+// List implements client.Client.
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	ctx, span := startSpan(ctx, "List", attribute.String("kind", reflect.TypeOf(list).Elem().Name()))
+	err := c.Client.List(ctx, list, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// Create implements client.Client.
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, span := startSpan(ctx, "Create", objectAttributes(obj, client.ObjectKeyFromObject(obj))...)
+	err := c.Client.Create(ctx, obj, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// Update implements client.Client.
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := startSpan(ctx, "Update", objectAttributes(obj, client.ObjectKeyFromObject(obj))...)
+	err := c.Client.Update(ctx, obj, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// Patch implements client.Client.
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	ctx, span := startSpan(ctx, "Patch", objectAttributes(obj, client.ObjectKeyFromObject(obj))...)
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// Delete implements client.Client.
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, span := startSpan(ctx, "Delete", objectAttributes(obj, client.ObjectKeyFromObject(obj))...)
+	err := c.Client.Delete(ctx, obj, opts...)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestReconcileTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	r, c := MockReconciler(MockCapiSecret(validMock, validType, validKey, "test-kubeconfig", TestNamespace))
+	r.Client = WithTracing(c)
+	_, err := r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+	var reconcile tracetest.SpanStub
+	for _, s := range spans {
+		if s.Name == "Reconcile" {
+			reconcile = s
+		}
+	}
+	assert.Equal(t, "Reconcile", reconcile.Name)
+	assert.Subset(t, reconcile.Attributes, []attribute.KeyValue{
+		attribute.String("namespace", TestNamespace),
+		attribute.String("name", "test-kubeconfig"),
+		attribute.String("outcome", "created"),
+	})
+
+	// API calls are children of the reconcile span.
+	children := map[string]bool{}
+	for _, s := range spans {
+		if s.Parent.SpanID() == reconcile.SpanContext.SpanID() {
+			children[s.Name] = true
+		}
+	}
+	assert.True(t, children["Get"])
+	assert.True(t, children["Create"])
+	for _, s := range spans {
+		if s.Name == "Create" {
+			assert.Contains(t, s.Attributes, attribute.String("kind", "Secret"))
+			assert.Contains(t, s.Attributes, attribute.String("name", "cluster-test"))
+		}
+	}
+
+	// Reconciling again updates nothing.
+	exporter.Reset()
+	_, err = r.Reconcile(context.Background(), MockReconcileReq("test-kubeconfig", TestNamespace))
+	assert.Nil(t, err)
+	for _, s := range exporter.GetSpans() {
+		assert.NotEqual(t, "Update", s.Name)
+		if s.Name == "Reconcile" {
+			assert.Contains(t, s.Attributes, attribute.String("outcome", "unchanged"))
+		}
+	}
+}
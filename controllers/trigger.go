@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// ReconcileTrigger serves POST /reconcile/{namespace}/{name}, which enqueues the given
+// CAPI secret for immediate reconciliation instead of waiting for the next resync.
+type ReconcileTrigger struct {
+	// Addr is the address the API binds to.
+	Addr string
+	// Token authenticates requests, sent as an "Authorization: Bearer <token>" header.
+	Token string
+	// Resync is the source channel of the Capi2Argo controller.
+	Resync chan<- event.GenericEvent
+	Log    logr.Logger
+}
+
+// Handler returns the trigger HTTP handler.
+func (t *ReconcileTrigger) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /reconcile/{namespace}/{name}", t.reconcile)
+	return mux
+}
+
+func (t *ReconcileTrigger) reconcile(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	nn := types.NamespacedName{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+	if !ValidateCapiNaming(nn) {
+		http.Error(w, "not a CAPI kubeconfig secret name", http.StatusBadRequest)
+		return
+	}
+
+	e := event.GenericEvent{Object: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}}
+	select {
+	case t.Resync <- e:
+		t.Log.Info("Enqueued on-demand reconcile", "secret", nn)
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+		http.Error(w, "timed out enqueueing reconcile", http.StatusServiceUnavailable)
+	}
+}
+
+// Start implements manager.Runnable, serving the trigger API until ctx is done.
+func (t *ReconcileTrigger) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              t.Addr,
+		Handler:           t.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	t.Log.Info("Serving reconcile trigger API", "address", t.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader runs
+// the controller consuming Resync, so only the leader serves the API.
+func (t *ReconcileTrigger) NeedLeaderElection() bool {
+	return true
+}
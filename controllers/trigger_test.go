@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcileTrigger(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		testName           string
+		testMethod         string
+		testPath           string
+		testToken          string
+		testExpectedStatus int
+		testExpectedEvent  bool
+	}{
+		{"test valid request", http.MethodPost, "/reconcile/test/test-kubeconfig", "Bearer secret", http.StatusAccepted, true},
+		{"test missing token", http.MethodPost, "/reconcile/test/test-kubeconfig", "", http.StatusUnauthorized, false},
+		{"test wrong token", http.MethodPost, "/reconcile/test/test-kubeconfig", "Bearer wrong", http.StatusUnauthorized, false},
+		{"test non-kubeconfig name", http.MethodPost, "/reconcile/test/test", "Bearer secret", http.StatusBadRequest, false},
+		{"test wrong method", http.MethodGet, "/reconcile/test/test-kubeconfig", "Bearer secret", http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			resync := make(chan event.GenericEvent, 1)
+			trigger := &ReconcileTrigger{Token: "secret", Resync: resync, Log: TestLog}
+
+			req := httptest.NewRequest(tt.testMethod, tt.testPath, nil)
+			if tt.testToken != "" {
+				req.Header.Set("Authorization", tt.testToken)
+			}
+			rec := httptest.NewRecorder()
+			trigger.Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.testExpectedStatus, rec.Code)
+			if !tt.testExpectedEvent {
+				assert.Len(t, resync, 0)
+				return
+			}
+			assert.Len(t, resync, 1)
+			e := <-resync
+			assert.Equal(t, "test-kubeconfig", e.Object.GetName())
+			assert.Equal(t, "test", e.Object.GetNamespace())
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/dntosas/capi2argo-cluster-operator/controllers"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// runExport implements the export subcommand, writing the ArgoSecrets owned by CACO to
+// stdout as a YAML stream.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	namespace := fs.String("namespace", controllers.ArgoNamespace, "The namespace to export ArgoSecrets from. Empty exports all namespaces.")
+	redact := fs.Bool("redact", true, "Replace the config holding cluster credentials, and any other data but the cluster name, server, project and namespaces, with a placeholder.")
+	fs.StringVar(&controllers.ArgoNameKey, "argocd-name-key", controllers.ArgoNameKey, "ArgoSecret data key holding the cluster name, for ArgoCD forks using another one.")
+	fs.StringVar(&controllers.ArgoServerKey, "argocd-server-key", controllers.ArgoServerKey, "ArgoSecret data key holding the cluster server, for ArgoCD forks using another one.")
+	fs.StringVar(&controllers.ArgoConfigKey, "argocd-config-key", controllers.ArgoConfigKey, "ArgoSecret data key holding the cluster config, for ArgoCD forks using another one.")
+	opts := zap.Options{}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+	// Logs go to stderr, keeping stdout for the YAML stream.
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+	if err := controllers.ExportArgoSecrets(context.Background(), c, os.Stdout, *namespace, *redact); err != nil {
+		setupLog.Error(err, "unable to export ArgoSecrets")
+		return 1
+	}
+	return 0
+}
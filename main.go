@@ -18,23 +18,34 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
 	"time"
 
+	capi2argov1alpha1 "github.com/dntosas/capi2argo-cluster-operator/api/v1alpha1"
 	"github.com/dntosas/capi2argo-cluster-operator/controllers"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -46,23 +57,110 @@ var (
 func init() {
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(capi2argov1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
-	var enableDryRun bool
 	var enableDebugMode bool
 	var probeAddr string
+	var configMap string
+	var extraOwnerLabels string
+	var applicationSetLabels string
+	var templatedLabels string
+	var requiredSourceLabels string
+	var ignoreConfigFields string
+	var clusterNameAllowlist string
+	var allowedArgoNamespaces string
+	var watchNamespaces string
+	var capiSecretTypes string
+	var kubeConfigDataKeys string
+	var credentialsSecret string
+	var caConfigMap string
+	var clusterLabelSelector string
 	var syncDuration time.Duration
+	var verbosity int
+	var apiAddr string
+	var apiToken string
+	var migrateLabels string
+	var otelEndpoint string
+	var statusConfigMap string
+	var phaseMetricsInterval time.Duration
+	var managedMetricsInterval time.Duration
 	defaultSyncDuration, _ := time.ParseDuration("45s")
 
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to. Use 0 to disable it.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.DurationVar(&syncDuration, "sync-duration", defaultSyncDuration, "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableDryRun, "dry-run", false, "Run in dry-run mode.")
+	flag.DurationVar(&syncDuration, "sync-duration", defaultSyncDuration, "Interval of resyncing all watched objects, correcting drifted ArgoSecrets without waiting for an event.")
+	flag.BoolVar(&controllers.EnableDryRun, "dry-run", false, "Log the ArgoSecrets that would be created, updated or deleted, along with their diff, without writing anything.")
 	flag.BoolVar(&enableDebugMode, "debug", false, "Run in debug mode.")
+	flag.IntVar(&verbosity, "v", 0, "Log verbosity level. Higher levels enable more detailed reconcile tracing.")
+	flag.BoolVar(&controllers.EnableCompressConfig, "experimental-compress-config", false, "Store configs exceeding the Secret size limit gzip-compressed. Upstream ArgoCD cannot read them.")
+	flag.StringVar(&configMap, "config-map", "", "The <namespace>/<name> of a ConfigMap to read runtime configuration from. Changes are applied without restart.")
+	flag.StringVar(&extraOwnerLabels, "extra-owner-labels", "", "Comma-separated key=value labels that mark non-CAPI typed secrets (eg. External Secrets Operator managed) as valid sources.")
+	flag.StringVar(&applicationSetLabels, "applicationset-labels", "", "Comma-separated key=value static labels set on every ArgoSecret, eg. for ApplicationSet cluster generators to select CACO-managed clusters.")
+	flag.StringVar(&templatedLabels, "templated-labels", "", "Comma-separated key=template labels set on every ArgoSecret, rendered from the Cluster Name, Namespace, Labels and Annotations, eg. tenant={{.Namespace}}.")
+	flag.BoolVar(&controllers.EnableClusterRegistrations, "enable-cluster-registrations", false, "Reconcile ClusterRegistration resources. Requires the ClusterRegistration CRD to be installed.")
+	flag.BoolVar(&controllers.WatchConfigMaps, "watch-configmaps", false, "Also reconcile <clusterName>-kubeconfig ConfigMaps holding non-sensitive kubeconfigs.")
+	flag.StringVar(&controllers.ServerSource, "server-source", controllers.ServerSourceKubeConfig, "Where the ArgoCD server is derived from: kubeconfig or control-plane-endpoint.")
+	flag.StringVar(&controllers.ConfigSource, "config-source", controllers.ConfigSourceKubeConfig, "Which ArgoCD config fields are derived from the kubeconfig: kubeconfig or server-ca-only.")
+	flag.StringVar(&credentialsSecret, "credentials-secret", "", "The <namespace>/<name> of a secret holding bearerToken or certData/keyData, used with --config-source=server-ca-only.")
+	flag.StringVar(&controllers.SingleNamespace, "single-namespace", "", "Restrict the controller, its cache and ArgoSecrets to a single namespace, which must hold both CAPI secrets and ArgoCD.")
+	flag.BoolVar(&controllers.SanitizeNames, "sanitize-names", false, "Normalize generated ArgoSecret names into valid DNS-1123 names (lowercase, invalid characters replaced by '-').")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "", "Comma-separated namespaces to sync CAPI secrets from, as literal names or globs, eg. capi-system,team-*. Empty watches all namespaces.")
+	flag.StringVar(&controllers.ShadowNamespace, "shadow-namespace", "", "Mirror ArgoSecrets into this namespace, labeled capi-to-argocd/shadow=true, for validation before promotion.")
+	flag.StringVar(&caConfigMap, "ca-configmap", "", "The <namespace>/<name>/<key> of a ConfigMap holding a PEM CA bundle (eg. a trust-manager Bundle target), used when the kubeconfig has no CA.")
+	flag.BoolVar(&controllers.ForceCABundle, "force-ca-configmap", false, "Use the --ca-configmap CA bundle even when the kubeconfig has a CA.")
+	flag.StringVar(&clusterLabelSelector, "cluster-label-selector", "", "Only register clusters whose Cluster object matches this label selector (eg. 'env in (prod,staging)').")
+	flag.StringVar(&allowedArgoNamespaces, "allowed-argocd-namespaces", "", "Comma-separated namespaces ArgoSecrets may be written to, whether the ArgoCD namespace or routed via the capi-to-argocd/argocd-instance annotation. Empty allows the ArgoCD namespace only.")
+	flag.BoolVar(&controllers.AllowServerTemplates, "allow-server-templates", false, "Sync Clusters annotated with capi-to-argocd/server-template, which are skipped otherwise.")
+	flag.StringVar(&clusterNameAllowlist, "cluster-name-allowlist", "", "Comma-separated clusters to register, as <name> or <namespace>/<name>, eg. for staged rollouts. Empty registers all clusters.")
+	flag.IntVar(&controllers.MaxTakeAlongLabels, "max-takealong-labels", 0, "Maximum take-along labels per ArgoSecret, extra ones are dropped in key order. Zero disables the limit.")
+	flag.BoolVar(&controllers.WarnEmptyTakeAlongValues, "warn-empty-takealong-values", false, "Log a warning, and count it in caco_takealong_empty_values_total, for take-along labels with an empty value on the Cluster. They are still taken along.")
+	flag.StringVar(&ignoreConfigFields, "ignore-config-fields", "", "Comma-separated ArgoSecret config fields, as dotted paths (eg. tlsClientConfig.serverName), whose out-of-band changes are not reverted, eg. the ones ArgoCD sets itself.")
+	flag.StringVar(&controllers.DriftCorrectionMode, "drift-correction-mode", controllers.DriftCorrectionModeUpdate, "How out-of-sync ArgoSecrets are written back: update sends the whole object, patch only the changed fields.")
+	flag.StringVar(&controllers.OverlongLabelPolicy, "overlong-label-policy", controllers.OverlongLabelPolicySkip, "How to handle take-along label values longer than 63 characters: skip, annotate or truncate.")
+	flag.StringVar(&apiAddr, "api-bind-address", "", "The address the on-demand reconcile API (POST /reconcile/<namespace>/<name>) binds to. Disabled if empty.")
+	flag.StringVar(&apiToken, "api-token", os.Getenv("CACO_API_TOKEN"), "The bearer token authenticating on-demand reconcile API requests. Defaults to $CACO_API_TOKEN.")
+	flag.BoolVar(&controllers.AllowEmptyUsers, "allow-empty-users", false, "Accept kubeconfigs with no users, producing ArgoSecrets with only caData.")
+	flag.IntVar(&controllers.WriteConcurrency, "write-concurrency", 4, "Maximum number of ArgoSecret writes issued concurrently by a single reconcile.")
+	flag.DurationVar(&controllers.ReconcileTimeout, "reconcile-timeout", 0, "Maximum duration of a single reconcile, after which it is requeued. Zero disables the timeout.")
+	flag.StringVar(&controllers.ReconcilerIdentity, "reconciler-identity", os.Getenv("POD_NAME"), "Identity recorded in the capi-to-argocd/reconciled-by annotation of written ArgoSecrets. Defaults to $POD_NAME.")
+	flag.BoolVar(&controllers.RejectLoopbackServers, "reject-loopback-servers", controllers.RejectLoopbackServers, "Skip CAPI secrets whose kubeconfig server is a loopback address (eg. 127.0.0.1 or localhost), which ArgoCD cannot reach.")
+	flag.BoolVar(&controllers.AllowSelfRegistration, "allow-self-registration", false, "Register CAPI secrets pointing to the cluster CACO runs in, or annotated in-cluster, which are skipped otherwise.")
+	flag.StringVar(&requiredSourceLabels, "required-source-labels", "", "Comma-separated label keys CAPI secrets must carry to be synced, eg. environment,team.")
+	flag.StringVar(&capiSecretTypes, "capi-secret-types", string(controllers.CapiClusterSecretType), "Comma-separated secret types accepted as CAPI secrets. The default covers CAPI v1alpha3 and later. Add Opaque, eg. cluster.x-k8s.io/secret,Opaque, to also accept the kubeconfig secrets of CAPI v1alpha2; it is opt-in as any Opaque secret named <name>-kubeconfig would be registered.")
+	flag.StringVar(&kubeConfigDataKeys, "kubeconfig-data-keys", "value", "Comma-separated candidate data keys holding the kubeconfig of CAPI secrets, the first present one is used.")
+	flag.DurationVar(&controllers.CertExpiryWarning, "cert-expiry-warning", controllers.CertExpiryWarning, "Log a warning for kubeconfig client certificates expiring within this duration. Zero disables the warning.")
+	flag.DurationVar(&controllers.MaxReconcileBackoff, "max-reconcile-backoff", controllers.MaxReconcileBackoff, "Maximum backoff between retries of a failing reconcile.")
+	flag.StringVar(&controllers.CredentialPreference, "credential-preference", controllers.CredentialPreferenceBoth, "Credential registered for kubeconfigs holding both a bearer token and a client certificate: token, cert or both.")
+	flag.BoolVar(&controllers.OmitBearerToken, "omit-bearer-token", false, "Register clusters without a bearer token, eg. for ArgoCD to rely on impersonation.")
+	flag.StringVar(&migrateLabels, "migrate-labels", "", "A <from>=<to> pair of label prefixes (eg. capi2argo/=capi-to-argocd/), relabeling ArgoSecrets owned under the legacy <from> scheme once at startup.")
+	flag.BoolVar(&controllers.WaitForReadyCondition, "wait-for-ready-condition", false, "Hold off registering clusters in ArgoCD until their Cluster Ready condition is True.")
+	flag.StringVar(&controllers.KubernetesVersionLabel, "kubernetes-version-label", controllers.KubernetesVersionLabel, "Label set on ArgoSecrets to the Kubernetes version of the cluster topology, or its control plane. Empty disables it.")
+	flag.DurationVar(&managedMetricsInterval, "managed-metrics-interval", time.Minute, "Interval of refreshing caco_argocd_secrets_managed, the number of ArgoSecrets managed by CACO. Zero disables it.")
+	flag.DurationVar(&phaseMetricsInterval, "phase-metrics-interval", time.Minute, "Interval of exporting caco_clusters_by_phase, the registered clusters by CAPI Cluster phase. Zero disables it.")
+	flag.StringVar(&statusConfigMap, "status-configmap", "", "Name of a ConfigMap, in the ArgoCD namespace, to aggregate the synced and errored cluster counts into (eg. caco-status). Empty disables it.")
+	flag.StringVar(&controllers.OperatorNamespace, "operator-namespace", os.Getenv("POD_NAMESPACE"), "Namespace CACO runs in, whose capi-to-argocd/paused: \"true\" annotation pauses all reconciles. Defaults to $POD_NAMESPACE.")
+	flag.BoolVar(&controllers.RegisterAllContexts, "register-all-contexts", false, "Register every context of kubeconfigs holding several of them, as <name>-<context> ArgoSecrets.")
+	flag.StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint (eg. http://otel-collector:4318) to export traces of reconciles and their API calls to. Empty disables tracing.")
+	flag.BoolVar(&controllers.EnableExemplars, "enable-exemplars", false, "Attach trace IDs of traced reconciles as exemplars to caco_reconcile_duration_seconds, served in OpenMetrics format under /metrics/openmetrics.")
+	flag.BoolVar(&controllers.GCOnStartup, "gc-on-startup", false, "Once caches synced, garbage collect ArgoSecrets whose source was deleted while CACO was not running. Requires garbage collection to be enabled.")
+	flag.DurationVar(&controllers.GCRecheckDelay, "gc-recheck-delay", 0, "Delay before reconciling a deleted source again, right before garbage collecting its ArgoSecrets, so that a source recreated meanwhile is synced instead.")
+	flag.StringVar(&controllers.FieldManager, "field-manager", controllers.FieldManager, "Field manager name CACO writes as, eg. to tell its managed fields apart from other controllers sharing the ArgoSecrets.")
+	flag.StringVar(&controllers.RotationAnnotation, "rotation-annotation", "", "Source annotation (eg. a rotation timestamp) whose changes force a rewrite of the ArgoSecret config. Empty disables it.")
+	flag.BoolVar(&controllers.SkipDuplicateServers, "skip-duplicate-servers", false, "Skip registering clusters whose server is already registered by another ArgoSecret, instead of only warning about it.")
+	flag.StringVar(&controllers.ArgoNameKey, "argocd-name-key", controllers.ArgoNameKey, "ArgoSecret data key holding the cluster name, for ArgoCD forks using another one.")
+	flag.StringVar(&controllers.ArgoServerKey, "argocd-server-key", controllers.ArgoServerKey, "ArgoSecret data key holding the cluster server, for ArgoCD forks using another one.")
+	flag.StringVar(&controllers.ArgoConfigKey, "argocd-config-key", controllers.ArgoConfigKey, "ArgoSecret data key holding the cluster config, for ArgoCD forks using another one.")
+	flag.BoolVar(&controllers.TakeoverSecretType, "takeover-secret-type", false, "Take over the ArgoCD cluster secrets (argocd.argoproj.io/secret-type=cluster) named like the ArgoSecret of a cluster but created by another tool, and reconcile them.")
+	flag.BoolVar(&controllers.AllowRecreate, "allow-recreate", false, "Delete and recreate ArgoSecrets whose update is rejected for changing immutable fields.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. "+"Use this when deploying multiple pods so to ensure there is only one active controller manager.")
 	opts := zap.Options{
 		Development: enableDebugMode,
@@ -70,17 +168,186 @@ func main() {
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	if verbosity > 0 {
+		// logr verbosity N maps to zap level -N.
+		opts.Level = zapcore.Level(-verbosity)
+	}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if extraOwnerLabels != "" {
+		l, err := labels.ConvertSelectorToLabelsMap(extraOwnerLabels)
+		if err != nil {
+			setupLog.Error(err, "invalid extra-owner-labels", "extra-owner-labels", extraOwnerLabels)
+			os.Exit(1)
+		}
+		controllers.ExtraOwnerLabels = l
+	}
+
+	controllers.KubeConfigDataKeys = nil
+	for _, key := range strings.Split(kubeConfigDataKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			controllers.KubeConfigDataKeys = append(controllers.KubeConfigDataKeys, key)
+		}
+	}
+	if len(controllers.KubeConfigDataKeys) == 0 {
+		setupLog.Error(nil, "kubeconfig-data-keys must name at least one key", "kubeconfig-data-keys", kubeConfigDataKeys)
+		os.Exit(1)
+	}
+
+	argoKeys := map[string]bool{controllers.ArgoNameKey: true, controllers.ArgoServerKey: true, controllers.ArgoConfigKey: true}
+	if len(argoKeys) != 3 || argoKeys[""] {
+		setupLog.Error(nil, "argocd-name-key, argocd-server-key and argocd-config-key must be set and distinct")
+		os.Exit(1)
+	}
+
+	if requiredSourceLabels != "" {
+		controllers.RequiredSourceLabels = strings.Split(requiredSourceLabels, ",")
+	}
+
+	if ignoreConfigFields != "" {
+		controllers.IgnoreConfigFields = strings.Split(ignoreConfigFields, ",")
+	}
+
+	controllers.CapiSecretTypes = nil
+	for _, t := range strings.Split(capiSecretTypes, ",") {
+		controllers.CapiSecretTypes = append(controllers.CapiSecretTypes, corev1.SecretType(t))
+	}
+
+	if clusterNameAllowlist != "" {
+		controllers.ClusterNameAllowlist = strings.Split(clusterNameAllowlist, ",")
+	}
+
+	if watchNamespaces != "" {
+		controllers.WatchNamespaces = strings.Split(watchNamespaces, ",")
+		if err := controllers.ValidateWatchNamespacePatterns(controllers.WatchNamespaces); err != nil {
+			setupLog.Error(err, "invalid watch-namespaces", "watch-namespaces", watchNamespaces)
+			os.Exit(1)
+		}
+	}
+
+	if allowedArgoNamespaces != "" {
+		controllers.AllowedArgoNamespaces = strings.Split(allowedArgoNamespaces, ",")
+	}
+
+	if applicationSetLabels != "" {
+		l, err := labels.ConvertSelectorToLabelsMap(applicationSetLabels)
+		if err != nil {
+			setupLog.Error(err, "invalid applicationset-labels", "applicationset-labels", applicationSetLabels)
+			os.Exit(1)
+		}
+		controllers.ApplicationSetLabels = l
+	}
+
+	if templatedLabels != "" {
+		t, err := controllers.ParseTemplatedLabels(templatedLabels)
+		if err != nil {
+			setupLog.Error(err, "invalid templated-labels", "templated-labels", templatedLabels)
+			os.Exit(1)
+		}
+		controllers.TemplatedLabels = t
+	}
+
+	switch controllers.OverlongLabelPolicy {
+	case controllers.OverlongLabelPolicySkip, controllers.OverlongLabelPolicyAnnotate, controllers.OverlongLabelPolicyTruncate:
+	default:
+		setupLog.Error(nil, "invalid overlong-label-policy", "overlong-label-policy", controllers.OverlongLabelPolicy)
+		os.Exit(1)
+	}
+
+	switch controllers.CredentialPreference {
+	case controllers.CredentialPreferenceBoth, controllers.CredentialPreferenceToken, controllers.CredentialPreferenceCert:
+	default:
+		setupLog.Error(nil, "invalid credential-preference", "credential-preference", controllers.CredentialPreference)
+		os.Exit(1)
+	}
+
+	switch controllers.DriftCorrectionMode {
+	case controllers.DriftCorrectionModeUpdate, controllers.DriftCorrectionModePatch:
+	default:
+		setupLog.Error(nil, "invalid drift-correction-mode", "drift-correction-mode", controllers.DriftCorrectionMode)
+		os.Exit(1)
+	}
+
+	switch controllers.ServerSource {
+	case controllers.ServerSourceKubeConfig, controllers.ServerSourceControlPlaneEndpoint:
+	default:
+		setupLog.Error(nil, "invalid server-source", "server-source", controllers.ServerSource)
+		os.Exit(1)
+	}
+
+	switch controllers.ConfigSource {
+	case controllers.ConfigSourceKubeConfig:
+	case controllers.ConfigSourceServerCAOnly:
+		namespace, name, found := strings.Cut(credentialsSecret, "/")
+		if !found {
+			setupLog.Error(nil, "invalid credentials-secret, expected <namespace>/<name>", "credentials-secret", credentialsSecret)
+			os.Exit(1)
+		}
+		controllers.CredentialsSecret = types.NamespacedName{Namespace: namespace, Name: name}
+	default:
+		setupLog.Error(nil, "invalid config-source", "config-source", controllers.ConfigSource)
+		os.Exit(1)
+	}
+
+	if clusterLabelSelector != "" {
+		selector, err := labels.Parse(clusterLabelSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid cluster-label-selector", "cluster-label-selector", clusterLabelSelector)
+			os.Exit(1)
+		}
+		controllers.ClusterSelector = selector
+	}
+
+	if configMap != "" {
+		namespace, name, found := strings.Cut(configMap, "/")
+		if !found {
+			setupLog.Error(nil, "invalid config-map, expected <namespace>/<name>", "config-map", configMap)
+			os.Exit(1)
+		}
+		controllers.RuntimeConfigMap = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	if caConfigMap != "" {
+		parts := strings.Split(caConfigMap, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			setupLog.Error(nil, "invalid ca-configmap, expected <namespace>/<name>/<key>", "ca-configmap", caConfigMap)
+			os.Exit(1)
+		}
+		controllers.CABundleConfigMap = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+		controllers.CABundleKey = parts[2]
+	}
+
+	if syncDuration <= 0 {
+		setupLog.Error(nil, "sync-duration must be positive", "sync-duration", syncDuration)
+		os.Exit(1)
+	}
+
+	if controllers.SingleNamespace != "" {
+		// Sources and ArgoSecrets share the namespace.
+		controllers.ArgoNamespace = controllers.SingleNamespace
+		// A Role can not grant access to Namespaces, which pausing reads.
+		if controllers.OperatorNamespace != "" {
+			setupLog.Info("pausing is not available in single-namespace mode", "operator-namespace", controllers.OperatorNamespace)
+			controllers.OperatorNamespace = ""
+		}
+	}
+
+	if err := controllers.ValidateArgoNamespaceSettings(controllers.ArgoNamespace); err != nil {
+		setupLog.Error(err, "invalid namespaces", "argocd-namespace", controllers.ArgoNamespace, "shadow-namespace", controllers.ShadowNamespace)
+		os.Exit(1)
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	controllers.SelfServer = restConfig.Host
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  controllers.CacheOptions(syncDuration),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "37cf8926.capi-cluster.x-argoproj.io",
-		// MetricsBindAddress:     metricsAddr,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		// Port:                   9443,
-		// SyncPeriod:             &syncDuration,
-		// DryRunClient:           enableDryRun,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -89,6 +356,25 @@ func main() {
 
 	//+kubebuilder:scaffold:builder
 
+	kubeClient := controllers.WithFieldManager(mgr.GetClient())
+	if otelEndpoint != "" {
+		tp, err := controllers.NewTracerProvider(context.Background(), otelEndpoint)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing", "otel-endpoint", otelEndpoint)
+			os.Exit(1)
+		}
+		defer func() { _ = tp.Shutdown(context.Background()) }()
+		kubeClient = controllers.WithTracing(kubeClient)
+	}
+
+	// Exemplars are only exposed in the OpenMetrics format, which the default /metrics endpoint does not negotiate.
+	if controllers.EnableExemplars {
+		if err := mgr.AddMetricsServerExtraHandler("/metrics/openmetrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})); err != nil {
+			setupLog.Error(err, "unable to set up OpenMetrics endpoint")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -98,15 +384,160 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.Capi2Argo{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("capi2argo"),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	var resync, configMapResync, registrationResync chan event.GenericEvent
+	if configMap != "" || apiAddr != "" {
+		resync = make(chan event.GenericEvent)
+	}
+	if configMap != "" && controllers.WatchConfigMaps {
+		configMapResync = make(chan event.GenericEvent)
+	}
+	if configMap != "" && controllers.EnableClusterRegistrations {
+		registrationResync = make(chan event.GenericEvent)
+	}
+
+	if apiAddr != "" {
+		if apiToken == "" {
+			setupLog.Error(nil, "api-token is required with api-bind-address")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controllers.ReconcileTrigger{
+			Addr:   apiAddr,
+			Token:  apiToken,
+			Resync: resync,
+			Log:    ctrl.Log.WithName("trigger"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add reconcile trigger API")
+			os.Exit(1)
+		}
+	}
+
+	if migrateLabels != "" {
+		from, to, found := strings.Cut(migrateLabels, "=")
+		if !found || from == "" || to == "" || from == to {
+			setupLog.Error(nil, "invalid migrate-labels, expected <from>=<to>", "migrate-labels", migrateLabels)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controllers.LabelMigration{
+			Client: kubeClient,
+			From:   from,
+			To:     to,
+			Log:    ctrl.Log.WithName("migration"),
+			DryRun: controllers.EnableDryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to add label migration")
+			os.Exit(1)
+		}
+	}
+
+	if configMap != "" {
+		if err = (&controllers.ConfigReconciler{
+			Client:             kubeClient,
+			Log:                ctrl.Log.WithName("config"),
+			Scheme:             mgr.GetScheme(),
+			ConfigMap:          controllers.RuntimeConfigMap,
+			Resync:             resync,
+			ConfigMapResync:    configMapResync,
+			RegistrationResync: registrationResync,
+			DryRun:             controllers.EnableDryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Config")
+			os.Exit(1)
+		}
+	}
+
+	var status *controllers.StatusReporter
+	if statusConfigMap != "" {
+		status = &controllers.StatusReporter{
+			Client:   kubeClient,
+			Name:     statusConfigMap,
+			Interval: 30 * time.Second,
+			Log:      ctrl.Log.WithName("status"),
+			DryRun:   controllers.EnableDryRun,
+		}
+		if err := mgr.Add(status); err != nil {
+			setupLog.Error(err, "unable to add status reporter")
+			os.Exit(1)
+		}
+	}
+
+	if managedMetricsInterval > 0 {
+		if err := mgr.Add(&controllers.ManagedSecretsReporter{
+			Client:   kubeClient,
+			Interval: managedMetricsInterval,
+			Log:      ctrl.Log.WithName("managed"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add managed secrets reporter")
+			os.Exit(1)
+		}
+	}
+
+	if phaseMetricsInterval > 0 {
+		if err := mgr.Add(&controllers.PhaseReporter{
+			Client:   kubeClient,
+			Interval: phaseMetricsInterval,
+			Log:      ctrl.Log.WithName("phases"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add phase reporter")
+			os.Exit(1)
+		}
+	}
+
+	capi2argo := &controllers.Capi2Argo{
+		Client:   kubeClient,
+		Log:      ctrl.Log.WithName("capi2argo"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("capi2argo"),
+		Resync:   resync,
+		Status:   status,
+		DryRun:   controllers.EnableDryRun,
+	}
+	if err = capi2argo.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Capi2Argo")
 		os.Exit(1)
 	}
 
+	if controllers.GCOnStartup {
+		if err := mgr.Add(&controllers.OrphanSweeper{
+			Capi2Argo:  capi2argo,
+			ConfigMaps: controllers.WatchConfigMaps,
+		}); err != nil {
+			setupLog.Error(err, "unable to add orphan sweeper")
+			os.Exit(1)
+		}
+	}
+
+	if controllers.WatchConfigMaps {
+		if err = (&controllers.KubeConfigMapReconciler{
+			Capi2Argo: controllers.Capi2Argo{
+				Client:   kubeClient,
+				Log:      ctrl.Log.WithName("kubeconfigmap"),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("capi2argo"),
+				Resync:   configMapResync,
+				DryRun:   controllers.EnableDryRun,
+			},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "KubeConfigMap")
+			os.Exit(1)
+		}
+	}
+
+	if controllers.EnableClusterRegistrations {
+		if err = (&controllers.ClusterRegistrationReconciler{
+			Client: kubeClient,
+			Log:    ctrl.Log.WithName("clusterregistration"),
+			Scheme: mgr.GetScheme(),
+			Resync: registrationResync,
+			DryRun: controllers.EnableDryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterRegistration")
+			os.Exit(1)
+		}
+	}
+
+	controllers.RecordFeatures(controllers.CurrentConfig())
+
+	setupLog.Info("serving metrics", "metrics-bind-address", metricsAddr)
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")